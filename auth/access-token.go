@@ -1,20 +1,90 @@
 package auth
 
 import (
+	"encoding/json"
+	"errors"
 	"github.com/1f349/mjwt"
 	"github.com/golang-jwt/jwt/v4"
 	"time"
 )
 
+var ErrProofOfPossessionRequired = errors.New("access token requires proof-of-possession")
+
 // AccessTokenClaims contains the JWT claims for an access token
+// Roles (rol) contains role names which are expanded using a RoleCatalogue
+// Actor (act) contains the delegation chain for exchanged tokens
+// Confirmation (cnf) binds the token to a proof-of-possession key
 type AccessTokenClaims struct {
-	Perms *PermStorage `json:"per"`
+	Perms        *PermStorage  `json:"per"`
+	Roles        []string      `json:"rol,omitempty"`
+	Actor        *Actor        `json:"act,omitempty"`
+	Confirmation *Confirmation `json:"cnf,omitempty"`
+
+	// CompactPerms encodes the per claim using PermStorage.EncodeCompact, the
+	// claim is decoded transparently when the token is verified
+	CompactPerms bool `json:"-"`
+}
+
+// Confirmation contains the cnf claim as defined in RFC 7800
+// JwkThumbprint (jkt) is the RFC 7638 thumbprint of a DPoP key
+// CertificateThumbprint (x5t#S256) is the RFC 8705 thumbprint of a client certificate
+type Confirmation struct {
+	JwkThumbprint         string `json:"jkt,omitempty"`
+	CertificateThumbprint string `json:"x5t#S256,omitempty"`
+}
+
+// Valid rejects access tokens bound to a DPoP key or client certificate, these
+// are only accepted by DPoPVerifier.VerifyRequest and
+// VerifyCertificateBoundRequest which check the binding
+func (a AccessTokenClaims) Valid() error {
+	if a.Confirmation != nil && *a.Confirmation != (Confirmation{}) {
+		return ErrProofOfPossessionRequired
+	}
+	return nil
 }
 
-func (a AccessTokenClaims) Valid() error { return nil }
+// MarshalJSON encodes the per claim in the compact form if CompactPerms is
+// enabled, permissions which cannot be compact encoded use the default form
+func (a AccessTokenClaims) MarshalJSON() ([]byte, error) {
+	type alias AccessTokenClaims
+	if !a.CompactPerms || a.Perms == nil {
+		return json.Marshal(alias(a))
+	}
+	compact, err := a.Perms.EncodeCompact()
+	if errors.Is(err, ErrPermNotCompactable) {
+		return json.Marshal(alias(a))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(struct {
+		alias
+		Perms string `json:"per"`
+	}{alias: alias(a), Perms: compact})
+}
 
 func (a AccessTokenClaims) Type() string { return "access-token" }
 
+// BoundAccessTokenClaims accepts access tokens with a cnf claim. This must only
+// be used when the binding is checked after verification or the cnf claim is
+// passed on to a party which checks it, such as an introspection response.
+type BoundAccessTokenClaims struct {
+	AccessTokenClaims
+}
+
+func (b BoundAccessTokenClaims) Valid() error { return nil }
+
+// extractBoundAccessToken verifies an access token which may have a cnf claim,
+// the caller must check the binding
+func extractBoundAccessToken(ks *mjwt.KeyStore, token string) (*jwt.Token, mjwt.BaseTypeClaims[AccessTokenClaims], error) {
+	tok, b, err := mjwt.ExtractClaims[BoundAccessTokenClaims](ks, token)
+	return tok, mjwt.BaseTypeClaims[AccessTokenClaims]{
+		RegisteredClaims: b.RegisteredClaims,
+		ClaimType:        b.ClaimType,
+		Claims:           b.Claims.AccessTokenClaims,
+	}, err
+}
+
 // CreateAccessToken creates an access token with the default 15 minute duration
 func CreateAccessToken(p *mjwt.Issuer, sub, id string, aud jwt.ClaimStrings, perms *PermStorage) (string, error) {
 	return CreateAccessTokenWithDuration(p, time.Minute*15, sub, id, aud, perms)
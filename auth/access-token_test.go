@@ -1,12 +1,23 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
 	"github.com/1f349/mjwt"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
 
+func newTestIssuer(t *testing.T, kid string, kStore *mjwt.KeyStore, opts ...mjwt.IssuerOption) *mjwt.Issuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	kStore.LoadPrivateKey(kid, key)
+	issuer, err := mjwt.NewIssuerWithKeyStore("mjwt.test", kid, jwt.SigningMethodRS512, kStore, opts...)
+	assert.NoError(t, err)
+	return issuer
+}
+
 func TestCreateAccessToken(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/1f349/mjwt"
+	"github.com/golang-jwt/jwt/v4"
+	"time"
+)
+
+// Common action token purposes
+const (
+	PurposeVerifyEmail   = "verify-email"
+	PurposeResetPassword = "reset-password"
+	PurposeInvite        = "invite"
+)
+
+// DefaultActionTokenDuration is the duration used by CreateActionToken
+const DefaultActionTokenDuration = 15 * time.Minute
+
+var ErrMissingPurpose = errors.New("missing pur claim")
+var ErrPurposeMismatch = errors.New("action token purpose mismatch")
+
+// ActionTokenClaims contains the JWT claims for a single-purpose action token
+// Purpose (pur) is the action the token can be used for
+// Target (tgt) is the resource the action applies to
+// Payload (pay) contains optional extra data for the action
+type ActionTokenClaims struct {
+	Purpose string          `json:"pur"`
+	Target  string          `json:"tgt,omitempty"`
+	Payload json.RawMessage `json:"pay,omitempty"`
+}
+
+func (a ActionTokenClaims) Valid() error {
+	if a.Purpose == "" {
+		return ErrMissingPurpose
+	}
+	return nil
+}
+
+func (a ActionTokenClaims) Type() string { return "action-token" }
+
+// DecodePayload unmarshals the payload into v, nothing is changed if there is
+// no payload
+func (a ActionTokenClaims) DecodePayload(v interface{}) error {
+	if len(a.Payload) == 0 {
+		return nil
+	}
+	return json.Unmarshal(a.Payload, v)
+}
+
+// NewActionTokenClaims creates ActionTokenClaims with the payload encoded as
+// JSON, a nil payload is omitted
+func NewActionTokenClaims(purpose, target string, payload interface{}) (ActionTokenClaims, error) {
+	if purpose == "" {
+		return ActionTokenClaims{}, ErrMissingPurpose
+	}
+	claims := ActionTokenClaims{Purpose: purpose, Target: target}
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return ActionTokenClaims{}, err
+		}
+		claims.Payload = b
+	}
+	return claims, nil
+}
+
+// CreateActionToken creates an action token with the default 15 minute
+// duration
+func CreateActionToken(p *mjwt.Issuer, sub string, aud jwt.ClaimStrings, purpose, target string, payload interface{}) (string, error) {
+	return CreateActionTokenWithDuration(p, DefaultActionTokenDuration, sub, aud, purpose, target, payload)
+}
+
+// CreateActionTokenWithDuration creates an action token with a custom
+// duration. A random JWT ID is always generated so the token can only be
+// consumed once.
+func CreateActionTokenWithDuration(p *mjwt.Issuer, dur time.Duration, sub string, aud jwt.ClaimStrings, purpose, target string, payload interface{}) (string, error) {
+	claims, err := NewActionTokenClaims(purpose, target, payload)
+	if err != nil {
+		return "", err
+	}
+	return p.Issue(claims,
+		mjwt.WithSubject(sub),
+		mjwt.WithRandomID(),
+		mjwt.WithAudience(aud...),
+		mjwt.WithDuration(dur),
+	)
+}
+
+// ExtractActionToken verifies an action token and checks the purpose matches
+// without recording the token as used
+func ExtractActionToken(ks *mjwt.KeyStore, token, purpose string) (*jwt.Token, mjwt.BaseTypeClaims[ActionTokenClaims], error) {
+	tok, b, err := mjwt.ExtractClaims[ActionTokenClaims](ks, token)
+	if err != nil {
+		return nil, b, err
+	}
+	if b.Claims.Purpose != purpose {
+		return nil, b, mjwt.NewVerifyError(mjwt.ReasonWrongType, ErrPurposeMismatch)
+	}
+	return tok, b, nil
+}
+
+// ConsumeActionToken verifies an action token, checks the purpose matches and
+// then records the token with the ReplayGuard so it can only be used once. A
+// token with the wrong purpose is not recorded.
+func ConsumeActionToken(ctx context.Context, ks *mjwt.KeyStore, guard *mjwt.ReplayGuard, token, purpose string) (*jwt.Token, mjwt.BaseTypeClaims[ActionTokenClaims], error) {
+	tok, b, err := ExtractActionToken(ks, token, purpose)
+	if err != nil {
+		return nil, b, err
+	}
+	if err := guard.Check(ctx, b.RegisteredClaims); err != nil {
+		return nil, b, err
+	}
+	return tok, b, nil
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"context"
+	"github.com/1f349/mjwt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestConsumeActionToken(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	kStore := mjwt.NewKeyStore()
+	s := newTestIssuer(t, "key1", kStore, mjwt.WithIDGenerator(nil))
+	guard := mjwt.NewReplayGuard(mjwt.NewMemoryReplayStore(1))
+
+	type invite struct {
+		Role string `json:"role"`
+	}
+	token, err := CreateActionToken(s, "1", nil, PurposeInvite, "team:5", invite{Role: "admin"})
+	assert.NoError(t, err)
+
+	// wrong purpose does not use up the token
+	_, _, err = ConsumeActionToken(ctx, kStore, guard, token, PurposeResetPassword)
+	assert.ErrorIs(t, err, ErrPurposeMismatch)
+	assert.ErrorIs(t, err, mjwt.ErrVerifyWrongType)
+
+	_, b, err := ConsumeActionToken(ctx, kStore, guard, token, PurposeInvite)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", b.Subject)
+	assert.NotEmpty(t, b.ID)
+	assert.Equal(t, "team:5", b.Claims.Target)
+	assert.WithinDuration(t, time.Now().Add(DefaultActionTokenDuration), b.ExpiresAt.Time, time.Minute)
+	var inv invite
+	assert.NoError(t, b.Claims.DecodePayload(&inv))
+	assert.Equal(t, "admin", inv.Role)
+
+	_, _, err = ConsumeActionToken(ctx, kStore, guard, token, PurposeInvite)
+	assert.ErrorIs(t, err, mjwt.ErrVerifyReplayed)
+}
+
+func TestCreateActionTokenWithDuration(t *testing.T) {
+	t.Parallel()
+
+	kStore := mjwt.NewKeyStore()
+	s := newTestIssuer(t, "key1", kStore)
+
+	token, err := CreateActionTokenWithDuration(s, -time.Minute, "1", nil, PurposeVerifyEmail, "user@example.com", nil)
+	assert.NoError(t, err)
+	_, b, err := ExtractActionToken(kStore, token, PurposeVerifyEmail)
+	assert.ErrorIs(t, err, mjwt.ErrVerifyExpired)
+	assert.Nil(t, b.Claims.Payload)
+
+	_, err = CreateActionToken(s, "1", nil, "", "", nil)
+	assert.ErrorIs(t, err, ErrMissingPurpose)
+}
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"errors"
+	"github.com/1f349/mjwt"
+	"github.com/becheran/wildmatch-go"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var ErrActorRequired = errors.New("actor required")
+var ErrActorChainTooDeep = errors.New("actor chain too deep")
+var ErrActorNotAllowed = errors.New("actor not allowed")
+
+// Actor contains the act claim as defined in RFC 8693 section 4.1. The
+// top-level Actor is the current acting party and nested Actor values are the
+// prior acting parties.
+type Actor struct {
+	Subject string `json:"sub"`
+	Issuer  string `json:"iss,omitempty"`
+	Actor   *Actor `json:"act,omitempty"`
+}
+
+// AppendActor adds a new current acting party to the start of the delegation
+// chain, the previous chain may be nil
+func AppendActor(chain *Actor, sub, iss string) *Actor {
+	return &Actor{Subject: sub, Issuer: iss, Actor: chain}
+}
+
+// Walk calls fn for each acting party starting with the current acting party,
+// walking stops if fn returns false
+func (a *Actor) Walk(fn func(depth int, actor *Actor) bool) {
+	for depth := 0; a != nil; depth++ {
+		if !fn(depth, a) {
+			return
+		}
+		a = a.Actor
+	}
+}
+
+// Depth outputs the number of acting parties in the delegation chain
+func (a *Actor) Depth() int {
+	n := 0
+	a.Walk(func(int, *Actor) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+// Chain outputs the subjects of the acting parties starting with the current
+// acting party
+func (a *Actor) Chain() []string {
+	var out []string
+	a.Walk(func(_ int, actor *Actor) bool {
+		out = append(out, actor.Subject)
+		return true
+	})
+	return out
+}
+
+// ActorPolicy limits the delegation chains accepted by a verifier
+type ActorPolicy struct {
+	// RequireActor rejects tokens without an act claim
+	RequireActor bool
+
+	// MaxDepth limits the number of acting parties, zero allows any depth
+	MaxDepth int
+
+	// AllowedActors contains wildcard patterns which every acting party
+	// subject must match, nil allows all acting parties
+	AllowedActors []string
+}
+
+// Check validates the delegation chain against the policy
+func (p ActorPolicy) Check(a *Actor) error {
+	if a == nil {
+		if p.RequireActor {
+			return ErrActorRequired
+		}
+		return nil
+	}
+	if p.MaxDepth > 0 && a.Depth() > p.MaxDepth {
+		return ErrActorChainTooDeep
+	}
+	if p.AllowedActors == nil {
+		return nil
+	}
+
+	matchers := make([]*wildmatch.WildMatch, len(p.AllowedActors))
+	for i, pattern := range p.AllowedActors {
+		matchers[i] = wildmatch.NewWildMatch(pattern)
+	}
+	var err error
+	a.Walk(func(_ int, actor *Actor) bool {
+		for _, m := range matchers {
+			if m.IsMatch(actor.Subject) {
+				return true
+			}
+		}
+		err = ErrActorNotAllowed
+		return false
+	})
+	return err
+}
+
+// ExtractAccessTokenWithActorPolicy verifies an access token and checks the
+// delegation chain against the ActorPolicy
+func ExtractAccessTokenWithActorPolicy(ks *mjwt.KeyStore, token string, policy ActorPolicy) (*jwt.Token, mjwt.BaseTypeClaims[AccessTokenClaims], error) {
+	tok, b, err := mjwt.ExtractClaims[AccessTokenClaims](ks, token)
+	if err != nil {
+		return nil, b, err
+	}
+	if err := policy.Check(b.Claims.Actor); err != nil {
+		return nil, b, mjwt.NewVerifyError(mjwt.ReasonInvalidClaims, err)
+	}
+	return tok, b, nil
+}
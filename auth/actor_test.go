@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"github.com/1f349/mjwt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestActor_Chain(t *testing.T) {
+	t.Parallel()
+	var a *Actor
+	assert.Equal(t, 0, a.Depth())
+	assert.Nil(t, a.Chain())
+
+	a = AppendActor(a, "service-a", "")
+	a = AppendActor(a, "service-b", "mjwt.test")
+	assert.Equal(t, 2, a.Depth())
+	assert.Equal(t, []string{"service-b", "service-a"}, a.Chain())
+	assert.Equal(t, "mjwt.test", a.Issuer)
+
+	var visited []int
+	a.Walk(func(depth int, _ *Actor) bool {
+		visited = append(visited, depth)
+		return false
+	})
+	assert.Equal(t, []int{0}, visited)
+}
+
+func TestActorPolicy_Check(t *testing.T) {
+	t.Parallel()
+	chain := AppendActor(AppendActor(nil, "service-a", ""), "service-b", "")
+
+	assert.NoError(t, ActorPolicy{}.Check(nil))
+	assert.NoError(t, ActorPolicy{}.Check(chain))
+	assert.ErrorIs(t, ActorPolicy{RequireActor: true}.Check(nil), ErrActorRequired)
+	assert.NoError(t, ActorPolicy{MaxDepth: 2}.Check(chain))
+	assert.ErrorIs(t, ActorPolicy{MaxDepth: 1}.Check(chain), ErrActorChainTooDeep)
+	assert.NoError(t, ActorPolicy{AllowedActors: []string{"service-*"}}.Check(chain))
+	assert.ErrorIs(t, ActorPolicy{AllowedActors: []string{"service-b"}}.Check(chain), ErrActorNotAllowed)
+}
+
+func TestExtractAccessTokenWithActorPolicy(t *testing.T) {
+	t.Parallel()
+
+	kStore := mjwt.NewKeyStore()
+	s := newTestIssuer(t, "key1", kStore)
+
+	token, err := s.GenerateJwt("user1", "", nil, time.Minute, AccessTokenClaims{
+		Perms: NewPermStorage(),
+		Actor: AppendActor(AppendActor(nil, "service-a", ""), "service-b", ""),
+	})
+	assert.NoError(t, err)
+
+	_, b, err := ExtractAccessTokenWithActorPolicy(kStore, token, ActorPolicy{RequireActor: true, MaxDepth: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"service-b", "service-a"}, b.Claims.Actor.Chain())
+
+	_, _, err = ExtractAccessTokenWithActorPolicy(kStore, token, ActorPolicy{MaxDepth: 1})
+	assert.ErrorIs(t, err, ErrActorChainTooDeep)
+	assert.ErrorIs(t, err, mjwt.ErrVerifyInvalidClaims)
+
+	plain, err := CreateAccessToken(s, "user1", "", jwt.ClaimStrings{"a"}, NewPermStorage())
+	assert.NoError(t, err)
+	_, _, err = ExtractAccessTokenWithActorPolicy(kStore, plain, ActorPolicy{RequireActor: true})
+	assert.ErrorIs(t, err, ErrActorRequired)
+}
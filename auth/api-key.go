@@ -0,0 +1,193 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"github.com/1f349/mjwt"
+	"github.com/golang-jwt/jwt/v4"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrMissingAPIKeyName = errors.New("missing api key name")
+var ErrAPIKeyNotFound = errors.New("api key not found")
+var ErrAPIKeyRevoked = errors.New("api key revoked")
+var ErrAPIKeyRegistryRequired = errors.New("api key without expiry requires a registry")
+
+// APIKeyClaims contains the JWT claims for a long-lived API key
+// Name (nam) is a human-readable label for the key
+// Owner (own) is the user or team responsible for the key
+// CreatedBy (cby) is the subject which created the key
+// Metadata (mta) contains free-form labels for the key
+type APIKeyClaims struct {
+	Name      string            `json:"nam"`
+	Owner     string            `json:"own,omitempty"`
+	CreatedBy string            `json:"cby,omitempty"`
+	Metadata  map[string]string `json:"mta,omitempty"`
+	Perms     *PermStorage      `json:"per"`
+}
+
+func (a APIKeyClaims) Valid() error {
+	if a.Name == "" {
+		return ErrMissingAPIKeyName
+	}
+	return nil
+}
+
+func (a APIKeyClaims) Type() string { return "api-key" }
+
+// APIKey is the registry entry for an issued API key
+type APIKey struct {
+	ID      string
+	Subject string
+	APIKeyClaims
+
+	CreatedAt time.Time
+	// ExpiresAt is zero for keys without an expiry
+	ExpiresAt time.Time
+	// RevokedAt is zero until the key is revoked
+	RevokedAt time.Time
+}
+
+// Revoked outputs true if the key has been revoked
+func (a *APIKey) Revoked() bool { return !a.RevokedAt.IsZero() }
+
+// APIKeyRegistry records issued API keys so they can be listed and revoked
+type APIKeyRegistry interface {
+	RegisterAPIKey(ctx context.Context, key *APIKey) error
+	GetAPIKey(ctx context.Context, id string) (*APIKey, error)
+	// ListAPIKeys outputs the keys for an owner, an empty owner lists all keys
+	ListAPIKeys(ctx context.Context, owner string) ([]*APIKey, error)
+	RevokeAPIKey(ctx context.Context, id string) error
+}
+
+// CreateAPIKey creates an API key token and records it in the registry. A
+// non-positive duration creates a key without an expiry, this requires a
+// registry. The registry can be nil for keys with an expiry.
+func CreateAPIKey(ctx context.Context, p *mjwt.Issuer, reg APIKeyRegistry, dur time.Duration, sub string, aud jwt.ClaimStrings, claims APIKeyClaims) (string, *APIKey, error) {
+	if err := claims.Valid(); err != nil {
+		return "", nil, err
+	}
+	if dur <= 0 && reg == nil {
+		return "", nil, ErrAPIKeyRegistryRequired
+	}
+
+	id, err := p.NewID()
+	if err != nil {
+		return "", nil, err
+	}
+	key := &APIKey{ID: id, Subject: sub, APIKeyClaims: claims, CreatedAt: time.Now()}
+	opts := []mjwt.TokenOption{
+		mjwt.WithSubject(sub),
+		mjwt.WithID(id),
+		mjwt.WithAudience(aud...),
+	}
+	if dur > 0 {
+		key.ExpiresAt = key.CreatedAt.Add(dur)
+		opts = append(opts, mjwt.WithDuration(dur))
+	} else {
+		opts = append(opts, mjwt.WithoutExpiry())
+	}
+
+	token, err := p.Issue(claims, opts...)
+	if err != nil {
+		return "", nil, err
+	}
+	if reg != nil {
+		if err := reg.RegisterAPIKey(ctx, key); err != nil {
+			return "", nil, err
+		}
+	}
+	return token, key, nil
+}
+
+// VerifyAPIKey verifies an API key token. If the registry is not nil the key
+// must be registered and not revoked. Keys without an expiry are rejected when
+// no registry is provided.
+func VerifyAPIKey(ctx context.Context, ks *mjwt.KeyStore, reg APIKeyRegistry, token string) (*jwt.Token, mjwt.BaseTypeClaims[APIKeyClaims], error) {
+	tok, b, err := mjwt.ExtractClaims[APIKeyClaims](ks, token)
+	if err != nil {
+		return nil, b, err
+	}
+	if reg == nil {
+		if b.ExpiresAt == nil {
+			return nil, b, mjwt.NewVerifyError(mjwt.ReasonInvalidClaims, ErrAPIKeyRegistryRequired)
+		}
+		return tok, b, nil
+	}
+
+	key, err := reg.GetAPIKey(ctx, b.ID)
+	switch {
+	case errors.Is(err, ErrAPIKeyNotFound):
+		return nil, b, mjwt.NewVerifyError(mjwt.ReasonRevoked, err)
+	case err != nil:
+		return nil, b, err
+	case key.Revoked():
+		return nil, b, mjwt.NewVerifyError(mjwt.ReasonRevoked, ErrAPIKeyRevoked)
+	}
+	return tok, b, nil
+}
+
+// MemoryAPIKeyRegistry is an in-memory APIKeyRegistry
+type MemoryAPIKeyRegistry struct {
+	mu   sync.RWMutex
+	keys map[string]*APIKey
+}
+
+// NewMemoryAPIKeyRegistry creates an empty MemoryAPIKeyRegistry
+func NewMemoryAPIKeyRegistry() *MemoryAPIKeyRegistry {
+	return &MemoryAPIKeyRegistry{keys: make(map[string]*APIKey)}
+}
+
+func (m *MemoryAPIKeyRegistry) RegisterAPIKey(_ context.Context, key *APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := *key
+	m.keys[key.ID] = &k
+	return nil
+}
+
+func (m *MemoryAPIKeyRegistry) GetAPIKey(_ context.Context, id string) (*APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	k, ok := m.keys[id]
+	if !ok {
+		return nil, ErrAPIKeyNotFound
+	}
+	c := *k
+	return &c, nil
+}
+
+func (m *MemoryAPIKeyRegistry) ListAPIKeys(_ context.Context, owner string) ([]*APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := make([]*APIKey, 0, len(m.keys))
+	for _, k := range m.keys {
+		if owner == "" || k.Owner == owner {
+			c := *k
+			keys = append(keys, &c)
+		}
+	}
+	slices.SortFunc(keys, func(a, b *APIKey) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return keys, nil
+}
+
+func (m *MemoryAPIKeyRegistry) RevokeAPIKey(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k, ok := m.keys[id]
+	if !ok {
+		return ErrAPIKeyNotFound
+	}
+	if k.RevokedAt.IsZero() {
+		k.RevokedAt = time.Now()
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"context"
+	"github.com/1f349/mjwt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestVerifyAPIKey(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	kStore := mjwt.NewKeyStore()
+	s := newTestIssuer(t, "key1", kStore)
+	reg := NewMemoryAPIKeyRegistry()
+
+	claims := APIKeyClaims{
+		Name:      "ci",
+		Owner:     "team:build",
+		CreatedBy: "1",
+		Metadata:  map[string]string{"repo": "mjwt"},
+		Perms:     ParsePermStorage("repo:read repo:write"),
+	}
+	token, key, err := CreateAPIKey(ctx, s, reg, 0, "bot:ci", nil, claims)
+	assert.NoError(t, err)
+	assert.True(t, key.ExpiresAt.IsZero())
+
+	_, b, err := VerifyAPIKey(ctx, kStore, reg, token)
+	assert.NoError(t, err)
+	assert.Nil(t, b.ExpiresAt)
+	assert.Equal(t, key.ID, b.ID)
+	assert.Equal(t, "bot:ci", b.Subject)
+	assert.Equal(t, "team:build", b.Claims.Owner)
+	assert.Equal(t, "mjwt", b.Claims.Metadata["repo"])
+	assert.True(t, b.Claims.Perms.Has("repo:write"))
+
+	// keys without an expiry need a registry
+	_, _, err = VerifyAPIKey(ctx, kStore, nil, token)
+	assert.ErrorIs(t, err, ErrAPIKeyRegistryRequired)
+	_, _, err = CreateAPIKey(ctx, s, nil, 0, "bot:ci", nil, claims)
+	assert.ErrorIs(t, err, ErrAPIKeyRegistryRequired)
+
+	assert.NoError(t, reg.RevokeAPIKey(ctx, key.ID))
+	_, _, err = VerifyAPIKey(ctx, kStore, reg, token)
+	assert.ErrorIs(t, err, ErrAPIKeyRevoked)
+	assert.ErrorIs(t, err, mjwt.ErrVerifyRevoked)
+
+	// unregistered keys are treated as revoked
+	token, _, err = CreateAPIKey(ctx, s, nil, time.Hour, "bot:ci", nil, claims)
+	assert.NoError(t, err)
+	_, _, err = VerifyAPIKey(ctx, kStore, nil, token)
+	assert.NoError(t, err)
+	_, _, err = VerifyAPIKey(ctx, kStore, reg, token)
+	assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+	assert.ErrorIs(t, err, mjwt.ErrVerifyRevoked)
+
+	_, _, err = CreateAPIKey(ctx, s, reg, 0, "bot:ci", nil, APIKeyClaims{})
+	assert.ErrorIs(t, err, ErrMissingAPIKeyName)
+}
+
+func TestMemoryAPIKeyRegistry(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	reg := NewMemoryAPIKeyRegistry()
+	now := time.Now()
+	assert.NoError(t, reg.RegisterAPIKey(ctx, &APIKey{ID: "b", APIKeyClaims: APIKeyClaims{Name: "b", Owner: "x"}, CreatedAt: now}))
+	assert.NoError(t, reg.RegisterAPIKey(ctx, &APIKey{ID: "a", APIKeyClaims: APIKeyClaims{Name: "a", Owner: "x"}, CreatedAt: now.Add(time.Second)}))
+	assert.NoError(t, reg.RegisterAPIKey(ctx, &APIKey{ID: "c", APIKeyClaims: APIKeyClaims{Name: "c", Owner: "y"}, CreatedAt: now}))
+
+	keys, err := reg.ListAPIKeys(ctx, "x")
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+	assert.Equal(t, "b", keys[0].ID)
+	assert.Equal(t, "a", keys[1].ID)
+
+	keys, err = reg.ListAPIKeys(ctx, "")
+	assert.NoError(t, err)
+	assert.Len(t, keys, 3)
+
+	// returned keys are copies
+	keys[0].Name = "changed"
+	k, err := reg.GetAPIKey(ctx, keys[0].ID)
+	assert.NoError(t, err)
+	assert.NotEqual(t, "changed", k.Name)
+
+	assert.ErrorIs(t, reg.RevokeAPIKey(ctx, "missing"), ErrAPIKeyNotFound)
+	_, err = reg.GetAPIKey(ctx, "missing")
+	assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+}
@@ -0,0 +1,243 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/1f349/mjwt"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/golang-jwt/jwt/v4"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DPoPProofType is the "typ" header value for DPoP proof JWTs
+const DPoPProofType = "dpop+jwt"
+
+var ErrMissingDPoPProof = errors.New("missing DPoP proof")
+var ErrInvalidDPoPProof = errors.New("invalid DPoP proof")
+var ErrDPoPReplay = errors.New("DPoP proof replayed")
+var ErrDPoPBindingMismatch = errors.New("DPoP key does not match the access token")
+
+// DPoPProofClaims contains the claims of an RFC 9449 DPoP proof JWT
+type DPoPProofClaims struct {
+	ID              string           `json:"jti"`
+	Method          string           `json:"htm"`
+	URI             string           `json:"htu"`
+	IssuedAt        *jwt.NumericDate `json:"iat"`
+	AccessTokenHash string           `json:"ath,omitempty"`
+}
+
+// Valid is checked by DPoPVerifier.VerifyProof instead
+func (d DPoPProofClaims) Valid() error { return nil }
+
+// DPoPProof contains the verified claims and public key thumbprint of a DPoP
+// proof
+type DPoPProof struct {
+	DPoPProofClaims
+	JwkThumbprint string
+}
+
+// JwkThumbprint calculates the base64url encoded RFC 7638 SHA-256 thumbprint
+// of a public key
+func JwkThumbprint(pub crypto.PublicKey) (string, error) {
+	jwk := jose.JSONWebKey{Key: pub}
+	b, err := jwk.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// accessTokenHash calculates the ath claim for an access token
+func accessTokenHash(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// CreateDPoPProof creates a DPoP proof for an HTTP request signed by the
+// private key. The access token is optional and sets the ath claim.
+func CreateDPoPProof(key crypto.Signer, method jwt.SigningMethod, htm, htu, accessToken string) (string, error) {
+	jwkJson, err := json.Marshal(jose.JSONWebKey{Key: key.Public()})
+	if err != nil {
+		return "", err
+	}
+	var jwk map[string]interface{}
+	if err := json.Unmarshal(jwkJson, &jwk); err != nil {
+		return "", err
+	}
+	id, err := mjwt.RandomIDGenerator.NewID()
+	if err != nil {
+		return "", err
+	}
+
+	claims := DPoPProofClaims{
+		ID:       id,
+		Method:   htm,
+		URI:      htu,
+		IssuedAt: jwt.NewNumericDate(time.Now()),
+	}
+	if accessToken != "" {
+		claims.AccessTokenHash = accessTokenHash(accessToken)
+	}
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["typ"] = DPoPProofType
+	token.Header["jwk"] = jwk
+	return token.SignedString(key)
+}
+
+// CreateDPoPBoundAccessToken creates an access token bound to the DPoP key
+// with the provided thumbprint
+func CreateDPoPBoundAccessToken(p *mjwt.Issuer, dur time.Duration, sub, id string, aud jwt.ClaimStrings, perms *PermStorage, jkt string) (string, error) {
+	return p.GenerateJwt(sub, id, aud, dur, AccessTokenClaims{
+		Perms:        perms,
+		Confirmation: &Confirmation{JwkThumbprint: jkt},
+	})
+}
+
+// DPoPVerifier verifies RFC 9449 DPoP proofs and the binding between proofs
+// and access tokens
+type DPoPVerifier struct {
+	Replay mjwt.ReplayStore
+
+	// MaxAge limits the time since the proof was issued, Leeway allows for
+	// clock skew between the client and the server
+	MaxAge time.Duration
+	Leeway time.Duration
+}
+
+// NewDPoPVerifier creates a DPoPVerifier with a 1 minute maximum age and 5
+// second leeway, a MemoryReplayStore is used if replay is nil
+func NewDPoPVerifier(replay mjwt.ReplayStore) *DPoPVerifier {
+	if replay == nil {
+		replay = mjwt.NewMemoryReplayStore(0)
+	}
+	return &DPoPVerifier{Replay: replay, MaxAge: time.Minute, Leeway: 5 * time.Second}
+}
+
+// VerifyProof checks the signature, type, method, URI and age of the DPoP
+// proof. If an access token is provided the ath claim must match.
+func (v *DPoPVerifier) VerifyProof(ctx context.Context, proof, htm, htu, accessToken string) (*DPoPProof, error) {
+	var claims DPoPProofClaims
+	var thumbprint string
+	_, err := jwt.ParseWithClaims(proof, &claims, func(token *jwt.Token) (interface{}, error) {
+		if typ, _ := token.Header["typ"].(string); typ != DPoPProofType {
+			return nil, errors.New("invalid typ header")
+		}
+		switch token.Method.(type) {
+		case *jwt.SigningMethodECDSA, *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodEd25519:
+		default:
+			return nil, errors.New("unsupported signing method")
+		}
+		jwkJson, err := json.Marshal(token.Header["jwk"])
+		if err != nil {
+			return nil, err
+		}
+		var jwk jose.JSONWebKey
+		if err := jwk.UnmarshalJSON(jwkJson); err != nil {
+			return nil, err
+		}
+		if !jwk.IsPublic() {
+			return nil, errors.New("jwk header must be a public key")
+		}
+		thumbprint, err = JwkThumbprint(jwk.Key)
+		if err != nil {
+			return nil, err
+		}
+		return jwk.Key, nil
+	})
+	if err != nil {
+		return nil, mjwt.NewVerifyError(mjwt.ReasonBadSignature, errors.Join(ErrInvalidDPoPProof, err))
+	}
+
+	invalid := func(msg string) error {
+		return mjwt.NewVerifyError(mjwt.ReasonInvalidClaims, errors.Join(ErrInvalidDPoPProof, errors.New(msg)))
+	}
+	if claims.ID == "" || claims.IssuedAt == nil {
+		return nil, invalid("missing jti or iat claim")
+	}
+	if claims.Method != htm {
+		return nil, invalid("htm mismatch")
+	}
+	if !sameHTU(claims.URI, htu) {
+		return nil, invalid("htu mismatch")
+	}
+	now := time.Now()
+	iat := claims.IssuedAt.Time
+	if iat.After(now.Add(v.Leeway)) || iat.Before(now.Add(-v.MaxAge-v.Leeway)) {
+		return nil, invalid("iat outside acceptable range")
+	}
+	if accessToken != "" && claims.AccessTokenHash != accessTokenHash(accessToken) {
+		return nil, invalid("ath mismatch")
+	}
+	fresh, err := v.Replay.Add(ctx, thumbprint+":"+claims.ID, iat.Add(v.MaxAge+v.Leeway))
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		return nil, mjwt.NewVerifyError(mjwt.ReasonReplayed, ErrDPoPReplay)
+	}
+	return &DPoPProof{DPoPProofClaims: claims, JwkThumbprint: thumbprint}, nil
+}
+
+// CheckDPoPBinding checks the access token is bound to the key of the DPoP
+// proof
+func CheckDPoPBinding(claims AccessTokenClaims, proof *DPoPProof) error {
+	if claims.Confirmation == nil || claims.Confirmation.JwkThumbprint == "" || claims.Confirmation.JwkThumbprint != proof.JwkThumbprint {
+		return mjwt.NewVerifyError(mjwt.ReasonInvalidClaims, ErrDPoPBindingMismatch)
+	}
+	return nil
+}
+
+// VerifyRequest verifies the DPoP bound access token from the "Authorization:
+// DPoP" header and the proof from the "DPoP" header of the request
+func (v *DPoPVerifier) VerifyRequest(ks *mjwt.KeyStore, req *http.Request) (*jwt.Token, mjwt.BaseTypeClaims[AccessTokenClaims], error) {
+	var b mjwt.BaseTypeClaims[AccessTokenClaims]
+	accessToken, ok := strings.CutPrefix(req.Header.Get("Authorization"), "DPoP ")
+	proofs := req.Header.Values("DPoP")
+	if !ok || len(proofs) != 1 {
+		return nil, b, mjwt.NewVerifyError(mjwt.ReasonMalformed, ErrMissingDPoPProof)
+	}
+
+	tok, b, err := extractBoundAccessToken(ks, accessToken)
+	if err != nil {
+		return nil, b, err
+	}
+	proof, err := v.VerifyProof(req.Context(), proofs[0], req.Method, RequestHTU(req), accessToken)
+	if err != nil {
+		return nil, b, err
+	}
+	if err := CheckDPoPBinding(b.Claims, proof); err != nil {
+		return nil, b, err
+	}
+	return tok, b, nil
+}
+
+// RequestHTU outputs the htu value for an incoming request, this is the URL
+// without the query and fragment
+func RequestHTU(req *http.Request) string {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + req.Host + req.URL.Path
+}
+
+// sameHTU compares the scheme, host and path of two URLs ignoring the query and
+// fragment
+func sameHTU(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(ua.Scheme, ub.Scheme) && strings.EqualFold(ua.Host, ub.Host) && ua.Path == ub.Path
+}
@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"github.com/1f349/mjwt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDPoPVerifier_VerifyRequest(t *testing.T) {
+	t.Parallel()
+
+	kStore := mjwt.NewKeyStore()
+	s := newTestIssuer(t, "key1", kStore)
+
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	jkt, err := JwkThumbprint(&clientKey.PublicKey)
+	assert.NoError(t, err)
+
+	accessToken, err := CreateDPoPBoundAccessToken(s, time.Minute, "1", "test", nil, ParsePermStorage("mjwt:test"), jkt)
+	assert.NoError(t, err)
+
+	newRequest := func(proof string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "http://api.example.com/resource?a=b", nil)
+		req.Header.Set("Authorization", "DPoP "+accessToken)
+		req.Header.Set("DPoP", proof)
+		return req
+	}
+
+	v := NewDPoPVerifier(nil)
+
+	t.Run("valid", func(t *testing.T) {
+		t.Parallel()
+		proof, err := CreateDPoPProof(clientKey, jwt.SigningMethodES256, http.MethodGet, "http://api.example.com/resource", accessToken)
+		assert.NoError(t, err)
+		_, b, err := v.VerifyRequest(kStore, newRequest(proof))
+		assert.NoError(t, err)
+		assert.Equal(t, "1", b.Subject)
+		assert.Equal(t, jkt, b.Claims.Confirmation.JwkThumbprint)
+
+		// proofs can only be used once
+		_, _, err = v.VerifyRequest(kStore, newRequest(proof))
+		assert.ErrorIs(t, err, ErrDPoPReplay)
+		assert.ErrorIs(t, err, mjwt.ErrVerifyReplayed)
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		t.Parallel()
+		otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		assert.NoError(t, err)
+		proof, err := CreateDPoPProof(otherKey, jwt.SigningMethodES256, http.MethodGet, "http://api.example.com/resource", accessToken)
+		assert.NoError(t, err)
+		_, _, err = v.VerifyRequest(kStore, newRequest(proof))
+		assert.ErrorIs(t, err, ErrDPoPBindingMismatch)
+	})
+
+	t.Run("wrong method", func(t *testing.T) {
+		t.Parallel()
+		proof, err := CreateDPoPProof(clientKey, jwt.SigningMethodES256, http.MethodPost, "http://api.example.com/resource", accessToken)
+		assert.NoError(t, err)
+		_, _, err = v.VerifyRequest(kStore, newRequest(proof))
+		assert.ErrorIs(t, err, ErrInvalidDPoPProof)
+	})
+
+	t.Run("wrong uri", func(t *testing.T) {
+		t.Parallel()
+		proof, err := CreateDPoPProof(clientKey, jwt.SigningMethodES256, http.MethodGet, "http://api.example.com/other", accessToken)
+		assert.NoError(t, err)
+		_, _, err = v.VerifyRequest(kStore, newRequest(proof))
+		assert.ErrorIs(t, err, ErrInvalidDPoPProof)
+	})
+
+	t.Run("missing access token hash", func(t *testing.T) {
+		t.Parallel()
+		proof, err := CreateDPoPProof(clientKey, jwt.SigningMethodES256, http.MethodGet, "http://api.example.com/resource", "")
+		assert.NoError(t, err)
+		_, _, err = v.VerifyRequest(kStore, newRequest(proof))
+		assert.ErrorIs(t, err, ErrInvalidDPoPProof)
+	})
+
+	t.Run("missing proof", func(t *testing.T) {
+		t.Parallel()
+		req := httptest.NewRequest(http.MethodGet, "http://api.example.com/resource", nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		_, _, err := v.VerifyRequest(kStore, req)
+		assert.ErrorIs(t, err, ErrMissingDPoPProof)
+	})
+
+	t.Run("bearer path", func(t *testing.T) {
+		t.Parallel()
+		_, _, err := mjwt.ExtractClaims[AccessTokenClaims](kStore, accessToken)
+		assert.ErrorIs(t, err, ErrProofOfPossessionRequired)
+		assert.ErrorIs(t, err, mjwt.ErrVerifyInvalidClaims)
+	})
+
+	t.Run("unbound access token", func(t *testing.T) {
+		t.Parallel()
+		bearer, err := CreateAccessToken(s, "1", "test", nil, NewPermStorage())
+		assert.NoError(t, err)
+		proof, err := CreateDPoPProof(clientKey, jwt.SigningMethodES256, http.MethodGet, "http://api.example.com/resource", bearer)
+		assert.NoError(t, err)
+		req := newRequest(proof)
+		req.Header.Set("Authorization", "DPoP "+bearer)
+		_, _, err = v.VerifyRequest(kStore, req)
+		assert.ErrorIs(t, err, ErrDPoPBindingMismatch)
+	})
+}
+
+func TestDPoPVerifier_VerifyProof(t *testing.T) {
+	t.Parallel()
+
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	v := NewDPoPVerifier(nil)
+
+	t.Run("symmetric algorithm", func(t *testing.T) {
+		t.Parallel()
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, DPoPProofClaims{ID: "1", Method: "GET", URI: "http://a/b", IssuedAt: jwt.NewNumericDate(time.Now())})
+		token.Header["typ"] = DPoPProofType
+		proof, err := token.SignedString([]byte("secret"))
+		assert.NoError(t, err)
+		_, err = v.VerifyProof(context.Background(), proof, "GET", "http://a/b", "")
+		assert.ErrorIs(t, err, ErrInvalidDPoPProof)
+		assert.ErrorIs(t, err, mjwt.ErrVerifyBadSignature)
+	})
+
+	t.Run("old proof", func(t *testing.T) {
+		t.Parallel()
+		old := &DPoPVerifier{Replay: mjwt.NewMemoryReplayStore(1), MaxAge: -time.Minute}
+		proof, err := CreateDPoPProof(clientKey, jwt.SigningMethodES256, "GET", "http://a/b", "")
+		assert.NoError(t, err)
+		_, err = old.VerifyProof(context.Background(), proof, "GET", "http://a/b", "")
+		assert.ErrorIs(t, err, ErrInvalidDPoPProof)
+	})
+
+	t.Run("uri comparison", func(t *testing.T) {
+		t.Parallel()
+		proof, err := CreateDPoPProof(clientKey, jwt.SigningMethodES256, "GET", "HTTPS://Example.com/b", "")
+		assert.NoError(t, err)
+		p, err := v.VerifyProof(context.Background(), proof, "GET", "https://example.com/b?x=y", "")
+		assert.NoError(t, err)
+		jkt, err := JwkThumbprint(&clientKey.PublicKey)
+		assert.NoError(t, err)
+		assert.Equal(t, jkt, p.JwkThumbprint)
+	})
+}
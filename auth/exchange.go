@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"errors"
+	"github.com/1f349/mjwt"
+	"github.com/golang-jwt/jwt/v4"
+	"strings"
+	"time"
+)
+
+var ErrSubjectTokenExpired = errors.New("subject token has expired")
+var ErrMissingActor = errors.New("missing acting party")
+var ErrRolesRequireCatalogue = errors.New("filtering a token with roles requires a role catalogue")
+
+// ExchangeOptions contains the settings for an RFC 8693 token exchange
+type ExchangeOptions struct {
+	// Actor is the subject of the acting party and ActorIssuer is the optional
+	// issuer of the acting party
+	Actor       string
+	ActorIssuer string
+
+	// Filter contains hierarchical permission patterns, the new token is
+	// granted the permissions granted by both the subject token and Filter.
+	// Nil keeps all permissions.
+	Filter []string
+
+	// Roles expands the rol claim of the subject token before Filter is
+	// applied, this is required to filter a subject token with roles
+	Roles *RoleCatalogue
+
+	// Audience replaces the audience of the subject token
+	Audience jwt.ClaimStrings
+
+	// Duration is limited to the remaining lifetime of the subject token, zero
+	// uses the remaining lifetime
+	Duration time.Duration
+
+	// ID is the JWT ID of the new token, if empty the Issuer generates an ID
+	ID string
+}
+
+// ExchangeAccessToken creates a down-scoped access token from a verified
+// subject access token. The permissions are narrowed using ExchangeOptions.Filter
+// and the acting party is added to the start of the act claim chain. Subject
+// tokens with roles are only filtered if ExchangeOptions.Roles is set,
+// otherwise ErrRolesRequireCatalogue is returned.
+func ExchangeAccessToken(p *mjwt.Issuer, subject mjwt.BaseTypeClaims[AccessTokenClaims], opts ExchangeOptions) (string, error) {
+	if opts.Actor == "" {
+		return "", ErrMissingActor
+	}
+
+	dur := opts.Duration
+	if subject.ExpiresAt != nil {
+		remaining := time.Until(subject.ExpiresAt.Time)
+		if remaining <= 0 {
+			return "", ErrSubjectTokenExpired
+		}
+		if dur == 0 || dur > remaining {
+			dur = remaining
+		}
+	}
+	if dur == 0 {
+		dur = time.Minute * 15
+	}
+
+	// roles are only kept when the permissions are not narrowed, otherwise
+	// the expanded roles could grant more than the filtered permissions
+	perms := subject.Claims.Perms
+	roles := subject.Claims.Roles
+	if perms == nil {
+		perms = NewPermStorage()
+	}
+	if opts.Filter != nil {
+		if len(roles) > 0 {
+			if opts.Roles == nil {
+				return "", ErrRolesRequireCatalogue
+			}
+			var err error
+			perms, err = opts.Roles.ExpandRoles(subject.Claims)
+			if err != nil {
+				return "", err
+			}
+		}
+		perms = perms.Intersect(ParsePermStorage(strings.Join(opts.Filter, " ")))
+		roles = nil
+	}
+
+	return p.GenerateJwt(subject.Subject, opts.ID, opts.Audience, dur, AccessTokenClaims{
+		Perms: perms,
+		Roles: roles,
+		Actor: AppendActor(subject.Claims.Actor, opts.Actor, opts.ActorIssuer),
+	})
+}
+
+// ExchangeToken verifies the subject access token using the KeyStore and then
+// creates a down-scoped access token using ExchangeAccessToken
+func ExchangeToken(p *mjwt.Issuer, ks *mjwt.KeyStore, subjectToken string, opts ExchangeOptions) (string, error) {
+	_, b, err := mjwt.ExtractClaims[AccessTokenClaims](ks, subjectToken)
+	if err != nil {
+		return "", err
+	}
+	return ExchangeAccessToken(p, b, opts)
+}
@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"github.com/1f349/mjwt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExchangeToken(t *testing.T) {
+	t.Parallel()
+
+	kStore := mjwt.NewKeyStore()
+	s := newTestIssuer(t, "key1", kStore)
+
+	ps := ParsePermStorage("files:read files:write files:delete users:read")
+	userToken, err := CreateAccessTokenWithDuration(s, time.Hour, "user1", "test", jwt.ClaimStrings{"service-a"}, ps)
+	assert.NoError(t, err)
+
+	exchanged, err := ExchangeToken(s, kStore, userToken, ExchangeOptions{
+		Actor:    "service-a",
+		Filter:   []string{"files:read", "users:*"},
+		Audience: jwt.ClaimStrings{"service-b"},
+		Duration: time.Minute,
+	})
+	assert.NoError(t, err)
+
+	_, b, err := mjwt.ExtractClaims[AccessTokenClaims](kStore, exchanged)
+	assert.NoError(t, err)
+	assert.Equal(t, "user1", b.Subject)
+	assert.NotEqual(t, "test", b.ID)
+	assert.Equal(t, jwt.ClaimStrings{"service-b"}, b.Audience)
+	assert.Equal(t, []string{"files:read", "users:read"}, b.Claims.Perms.Dump())
+	assert.Equal(t, &Actor{Subject: "service-a"}, b.Claims.Actor)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), b.ExpiresAt.Time, 2*time.Second)
+
+	t.Run("chained exchange", func(t *testing.T) {
+		t.Parallel()
+		chained, err := ExchangeAccessToken(s, b, ExchangeOptions{
+			Actor:       "service-b",
+			ActorIssuer: "mjwt.test",
+			Filter:      []string{"files:*"},
+			Audience:    jwt.ClaimStrings{"service-c"},
+			Duration:    time.Hour,
+		})
+		assert.NoError(t, err)
+
+		_, c, err := mjwt.ExtractClaims[AccessTokenClaims](kStore, chained)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"files:read"}, c.Claims.Perms.Dump())
+		assert.Equal(t, &Actor{Subject: "service-b", Issuer: "mjwt.test", Actor: &Actor{Subject: "service-a"}}, c.Claims.Actor)
+
+		// the duration is limited by the subject token
+		assert.False(t, c.ExpiresAt.After(b.ExpiresAt.Time))
+	})
+
+	t.Run("wildcard subject", func(t *testing.T) {
+		t.Parallel()
+		wildToken, err := CreateAccessTokenWithDuration(s, time.Hour, "user1", "", nil, ParsePermStorage("files:* !files:secret"))
+		assert.NoError(t, err)
+
+		exchanged, err := ExchangeToken(s, kStore, wildToken, ExchangeOptions{Actor: "service-a", Filter: []string{"files:read"}})
+		assert.NoError(t, err)
+		_, c, err := mjwt.ExtractClaims[AccessTokenClaims](kStore, exchanged)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"!files:secret", "files:read"}, c.Claims.Perms.Dump())
+
+		// the filter cannot be widened by a wildcard subject permission
+		exchanged, err = ExchangeToken(s, kStore, wildToken, ExchangeOptions{Actor: "service-a", Filter: []string{"files:?"}})
+		assert.NoError(t, err)
+		_, c, err = mjwt.ExtractClaims[AccessTokenClaims](kStore, exchanged)
+		assert.NoError(t, err)
+		assert.True(t, c.Claims.Perms.Grants("files:x"))
+		assert.False(t, c.Claims.Perms.Grants("files:delete"))
+	})
+
+	t.Run("missing actor", func(t *testing.T) {
+		t.Parallel()
+		_, err := ExchangeAccessToken(s, b, ExchangeOptions{})
+		assert.ErrorIs(t, err, ErrMissingActor)
+	})
+
+	t.Run("expired subject", func(t *testing.T) {
+		t.Parallel()
+		expired := b
+		expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+		_, err := ExchangeAccessToken(s, expired, ExchangeOptions{Actor: "service-a"})
+		assert.ErrorIs(t, err, ErrSubjectTokenExpired)
+	})
+}
+
+func TestExchangeAccessToken_Roles(t *testing.T) {
+	t.Parallel()
+
+	kStore := mjwt.NewKeyStore()
+	s := newTestIssuer(t, "key1", kStore)
+	subject := mjwt.BaseTypeClaims[AccessTokenClaims]{Claims: AccessTokenClaims{Perms: ParsePermStorage("files:read"), Roles: []string{"editor"}}}
+
+	token, err := ExchangeAccessToken(s, subject, ExchangeOptions{Actor: "service-a"})
+	assert.NoError(t, err)
+	_, b, err := mjwt.ExtractClaims[AccessTokenClaims](kStore, token)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"editor"}, b.Claims.Roles)
+
+	// roles cannot be narrowed without a catalogue
+	_, err = ExchangeAccessToken(s, subject, ExchangeOptions{Actor: "service-a", Filter: []string{"files:*"}})
+	assert.ErrorIs(t, err, ErrRolesRequireCatalogue)
+
+	// roles are expanded before the permissions are narrowed
+	c, err := LoadRoleCatalogue(strings.NewReader(testRoles))
+	assert.NoError(t, err)
+	token, err = ExchangeAccessToken(s, subject, ExchangeOptions{Actor: "service-a", Filter: []string{"files:*", "posts:read"}, Roles: c})
+	assert.NoError(t, err)
+	_, b, err = mjwt.ExtractClaims[AccessTokenClaims](kStore, token)
+	assert.NoError(t, err)
+	assert.Nil(t, b.Claims.Roles)
+	assert.Equal(t, []string{"!posts:delete", "files:read", "posts:read"}, b.Claims.Perms.Dump())
+
+	_, err = ExchangeAccessToken(s, mjwt.BaseTypeClaims[AccessTokenClaims]{Claims: AccessTokenClaims{Roles: []string{"missing"}}}, ExchangeOptions{Actor: "service-a", Filter: []string{"files:*"}, Roles: c})
+	assert.ErrorIs(t, err, ErrUnknownRole)
+}
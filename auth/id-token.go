@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"github.com/1f349/mjwt"
+	"github.com/golang-jwt/jwt/v4"
+	"strings"
+	"time"
+)
+
+var ErrUnsupportedHashAlg = errors.New("unsupported hash algorithm")
+var ErrAccessTokenHashMismatch = errors.New("access token hash mismatch")
+var ErrCodeHashMismatch = errors.New("code hash mismatch")
+var ErrNonceMismatch = errors.New("nonce mismatch")
+
+// IDTokenClaims contains the JWT claims for an OpenID Connect ID token along
+// with the standard profile claims
+type IDTokenClaims struct {
+	Nonce           string           `json:"nonce,omitempty"`
+	AuthTime        *jwt.NumericDate `json:"auth_time,omitempty"`
+	AuthorizedParty string           `json:"azp,omitempty"`
+	AccessTokenHash string           `json:"at_hash,omitempty"`
+	CodeHash        string           `json:"c_hash,omitempty"`
+	Acr             string           `json:"acr,omitempty"`
+	Amr             []string         `json:"amr,omitempty"`
+
+	Name                string           `json:"name,omitempty"`
+	GivenName           string           `json:"given_name,omitempty"`
+	FamilyName          string           `json:"family_name,omitempty"`
+	MiddleName          string           `json:"middle_name,omitempty"`
+	Nickname            string           `json:"nickname,omitempty"`
+	PreferredUsername   string           `json:"preferred_username,omitempty"`
+	Profile             string           `json:"profile,omitempty"`
+	Picture             string           `json:"picture,omitempty"`
+	Website             string           `json:"website,omitempty"`
+	Email               string           `json:"email,omitempty"`
+	EmailVerified       *bool            `json:"email_verified,omitempty"`
+	Gender              string           `json:"gender,omitempty"`
+	Birthdate           string           `json:"birthdate,omitempty"`
+	ZoneInfo            string           `json:"zoneinfo,omitempty"`
+	Locale              string           `json:"locale,omitempty"`
+	PhoneNumber         string           `json:"phone_number,omitempty"`
+	PhoneNumberVerified *bool            `json:"phone_number_verified,omitempty"`
+	UpdatedAt           *jwt.NumericDate `json:"updated_at,omitempty"`
+}
+
+func (i IDTokenClaims) Valid() error { return nil }
+
+func (i IDTokenClaims) Type() string { return "id-token" }
+
+// SetAccessTokenHash sets the at_hash claim for the access token using the hash
+// algorithm matching the signing method
+func (i *IDTokenClaims) SetAccessTokenHash(method jwt.SigningMethod, accessToken string) error {
+	h, err := ComputeHashClaim(method, accessToken)
+	if err != nil {
+		return err
+	}
+	i.AccessTokenHash = h
+	return nil
+}
+
+// SetCodeHash sets the c_hash claim for the authorization code using the hash
+// algorithm matching the signing method
+func (i *IDTokenClaims) SetCodeHash(method jwt.SigningMethod, code string) error {
+	h, err := ComputeHashClaim(method, code)
+	if err != nil {
+		return err
+	}
+	i.CodeHash = h
+	return nil
+}
+
+// ComputeHashClaim calculates the at_hash or c_hash value for an access token
+// or authorization code. The value is hashed with the hash algorithm matching
+// the signing method and the left-most half is base64url encoded.
+func ComputeHashClaim(method jwt.SigningMethod, value string) (string, error) {
+	var h crypto.Hash
+	alg := method.Alg()
+	switch {
+	case strings.HasSuffix(alg, "256"):
+		h = crypto.SHA256
+	case strings.HasSuffix(alg, "384"):
+		h = crypto.SHA384
+	case strings.HasSuffix(alg, "512"), alg == "EdDSA":
+		h = crypto.SHA512
+	default:
+		return "", ErrUnsupportedHashAlg
+	}
+	hash := h.New()
+	hash.Write([]byte(value))
+	sum := hash.Sum(nil)
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2]), nil
+}
+
+// CreateIDToken creates an ID token with the default 15 minute duration. If an
+// access token is provided the at_hash claim is set.
+func CreateIDToken(p *mjwt.Issuer, sub, id string, aud jwt.ClaimStrings, claims IDTokenClaims, accessToken string) (string, error) {
+	return CreateIDTokenWithDuration(p, time.Minute*15, sub, id, aud, claims, accessToken)
+}
+
+// CreateIDTokenWithDuration creates an ID token with a custom duration. If an
+// access token is provided the at_hash claim is set.
+func CreateIDTokenWithDuration(p *mjwt.Issuer, dur time.Duration, sub, id string, aud jwt.ClaimStrings, claims IDTokenClaims, accessToken string) (string, error) {
+	if accessToken != "" {
+		if err := claims.SetAccessTokenHash(p.SigningMethod(), accessToken); err != nil {
+			return "", err
+		}
+	}
+	return p.GenerateJwt(sub, id, aud, dur, claims)
+}
+
+// ExtractIDToken verifies an ID token on the relying-party side. The nonce and
+// access token are optional and are only checked when not empty.
+func ExtractIDToken(ks *mjwt.KeyStore, token, nonce, accessToken string) (*jwt.Token, mjwt.BaseTypeClaims[IDTokenClaims], error) {
+	tok, b, err := mjwt.ExtractClaims[IDTokenClaims](ks, token)
+	if err != nil {
+		return nil, b, err
+	}
+	if nonce != "" && subtle.ConstantTimeCompare([]byte(nonce), []byte(b.Claims.Nonce)) != 1 {
+		return nil, b, mjwt.NewVerifyError(mjwt.ReasonInvalidClaims, ErrNonceMismatch)
+	}
+	if accessToken != "" {
+		if err := ValidateAccessTokenHash(tok, b.Claims, accessToken); err != nil {
+			return nil, b, err
+		}
+	}
+	return tok, b, nil
+}
+
+// ValidateAccessTokenHash checks the at_hash claim matches the access token
+func ValidateAccessTokenHash(tok *jwt.Token, claims IDTokenClaims, accessToken string) error {
+	return validateHashClaim(tok, claims.AccessTokenHash, accessToken, ErrAccessTokenHashMismatch)
+}
+
+// ValidateCodeHash checks the c_hash claim matches the authorization code
+func ValidateCodeHash(tok *jwt.Token, claims IDTokenClaims, code string) error {
+	return validateHashClaim(tok, claims.CodeHash, code, ErrCodeHashMismatch)
+}
+
+func validateHashClaim(tok *jwt.Token, claim, value string, mismatch error) error {
+	h, err := ComputeHashClaim(tok.Method, value)
+	if err != nil {
+		return mjwt.NewVerifyError(mjwt.ReasonInvalidClaims, err)
+	}
+	if subtle.ConstantTimeCompare([]byte(h), []byte(claim)) != 1 {
+		return mjwt.NewVerifyError(mjwt.ReasonInvalidClaims, mismatch)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"github.com/1f349/mjwt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestComputeHashClaim(t *testing.T) {
+	t.Parallel()
+
+	// left-most half of sha256("test")
+	h, err := ComputeHashClaim(jwt.SigningMethodRS256, "test")
+	assert.NoError(t, err)
+	assert.Equal(t, "n4bQgYhMfWWaL-qgxVrQFQ", h)
+
+	h, err = ComputeHashClaim(jwt.SigningMethodRS512, "test")
+	assert.NoError(t, err)
+	assert.Len(t, h, 43)
+
+	_, err = ComputeHashClaim(jwt.SigningMethodNone, "test")
+	assert.ErrorIs(t, err, ErrUnsupportedHashAlg)
+}
+
+func TestCreateIDToken(t *testing.T) {
+	t.Parallel()
+
+	kStore := mjwt.NewKeyStore()
+	s := newTestIssuer(t, "key1", kStore)
+
+	accessToken, err := CreateAccessToken(s, "1", "test", nil, ParsePermStorage("mjwt:test"))
+	assert.NoError(t, err)
+
+	verified := true
+	claims := IDTokenClaims{
+		Nonce:           "n-0S6_WzA2Mj",
+		AuthorizedParty: "client1",
+		Name:            "Jane Doe",
+		Email:           "jane@example.com",
+		EmailVerified:   &verified,
+	}
+	assert.NoError(t, claims.SetCodeHash(s.SigningMethod(), "code1"))
+	idToken, err := CreateIDToken(s, "1", "test2", jwt.ClaimStrings{"client1"}, claims, accessToken)
+	assert.NoError(t, err)
+
+	t.Run("valid", func(t *testing.T) {
+		t.Parallel()
+		tok, b, err := ExtractIDToken(kStore, idToken, "n-0S6_WzA2Mj", accessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, "1", b.Subject)
+		assert.Equal(t, "client1", b.Claims.AuthorizedParty)
+		assert.Equal(t, "Jane Doe", b.Claims.Name)
+		assert.True(t, *b.Claims.EmailVerified)
+		assert.NotEmpty(t, b.Claims.AccessTokenHash)
+		assert.NoError(t, ValidateCodeHash(tok, b.Claims, "code1"))
+		assert.ErrorIs(t, ValidateCodeHash(tok, b.Claims, "code2"), ErrCodeHashMismatch)
+	})
+
+	t.Run("nonce mismatch", func(t *testing.T) {
+		t.Parallel()
+		_, _, err := ExtractIDToken(kStore, idToken, "other", "")
+		assert.ErrorIs(t, err, ErrNonceMismatch)
+		assert.ErrorIs(t, err, mjwt.ErrVerifyInvalidClaims)
+	})
+
+	t.Run("access token mismatch", func(t *testing.T) {
+		t.Parallel()
+		_, _, err := ExtractIDToken(kStore, idToken, "", accessToken+"a")
+		assert.ErrorIs(t, err, ErrAccessTokenHashMismatch)
+	})
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"errors"
+	"github.com/1f349/mjwt"
+	"github.com/golang-jwt/jwt/v4"
+	"strings"
+	"time"
+)
+
+// JWTAccessTokenType is the "typ" header value for RFC 9068 access tokens
+const JWTAccessTokenType = "at+jwt"
+
+var ErrMissingClientId = errors.New("missing client_id claim")
+var ErrMissingRequiredClaim = errors.New("missing required claim")
+
+// JWTAccessTokenClaims contains the JWT claims for an access token following
+// the RFC 9068 JWT profile for OAuth 2.0 access tokens. The Scope claim is a
+// space-delimited list of permissions.
+type JWTAccessTokenClaims struct {
+	ClientId string           `json:"client_id"`
+	Scope    string           `json:"scope,omitempty"`
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	Acr      string           `json:"acr,omitempty"`
+	Amr      []string         `json:"amr,omitempty"`
+}
+
+func (j JWTAccessTokenClaims) Valid() error {
+	if j.ClientId == "" {
+		return ErrMissingClientId
+	}
+	return nil
+}
+
+func (j JWTAccessTokenClaims) Type() string { return "jwt-access-token" }
+
+// Perms outputs the Scope claim as a PermStorage
+func (j JWTAccessTokenClaims) Perms() *PermStorage { return ParsePermStorage(j.Scope) }
+
+// NewJWTAccessTokenClaims creates JWTAccessTokenClaims with the scope set from
+// the permissions
+func NewJWTAccessTokenClaims(clientId string, perms *PermStorage) JWTAccessTokenClaims {
+	return JWTAccessTokenClaims{ClientId: clientId, Scope: FormatScope(perms)}
+}
+
+// FormatScope outputs the permissions as a space-delimited scope string
+func FormatScope(perms *PermStorage) string {
+	if perms == nil {
+		return ""
+	}
+	return strings.Join(perms.Dump(), " ")
+}
+
+// CreateJWTAccessToken creates an RFC 9068 access token with the default 15
+// minute duration
+func CreateJWTAccessToken(p *mjwt.Issuer, sub, id string, aud jwt.ClaimStrings, claims JWTAccessTokenClaims) (string, error) {
+	return CreateJWTAccessTokenWithDuration(p, time.Minute*15, sub, id, aud, claims)
+}
+
+// CreateJWTAccessTokenWithDuration creates an RFC 9068 access token with a
+// custom duration
+func CreateJWTAccessTokenWithDuration(p *mjwt.Issuer, dur time.Duration, sub, id string, aud jwt.ClaimStrings, claims JWTAccessTokenClaims) (string, error) {
+	return p.Issue(claims,
+		mjwt.WithSubject(sub),
+		mjwt.WithID(id),
+		mjwt.WithAudience(aud...),
+		mjwt.WithDuration(dur),
+		mjwt.WithHeader("typ", JWTAccessTokenType),
+	)
+}
+
+// ExtractJWTAccessToken verifies an RFC 9068 access token. The "typ" header
+// must be "at+jwt" and the iss, exp, aud, sub, iat and jti claims must be
+// present.
+func ExtractJWTAccessToken(ks *mjwt.KeyStore, token string) (*jwt.Token, mjwt.BaseTypeClaims[JWTAccessTokenClaims], error) {
+	tok, b, err := mjwt.ExtractClaims[JWTAccessTokenClaims](ks, token)
+	if err != nil {
+		return nil, b, err
+	}
+	typ, _ := tok.Header["typ"].(string)
+	typ = strings.TrimPrefix(strings.ToLower(typ), "application/")
+	if typ != JWTAccessTokenType {
+		return nil, b, mjwt.NewVerifyError(mjwt.ReasonWrongType, errors.New("invalid typ header: "+typ))
+	}
+	if b.Issuer == "" || b.ExpiresAt == nil || len(b.Audience) == 0 || b.Subject == "" || b.IssuedAt == nil || b.ID == "" {
+		return nil, b, mjwt.NewVerifyError(mjwt.ReasonInvalidClaims, ErrMissingRequiredClaim)
+	}
+	return tok, b, nil
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"encoding/json"
+	"github.com/1f349/mjwt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCreateJWTAccessToken(t *testing.T) {
+	t.Parallel()
+
+	ps := ParsePermStorage("mjwt:test mjwt:test2")
+
+	kStore := mjwt.NewKeyStore()
+	s := newTestIssuer(t, "key1", kStore)
+
+	claims := NewJWTAccessTokenClaims("client1", ps)
+	claims.AuthTime = jwt.NewNumericDate(time.Now())
+	claims.Acr = "urn:mace:incommon:iap:silver"
+	claims.Amr = []string{"pwd", "otp"}
+	accessToken, err := CreateJWTAccessToken(s, "1", "test", jwt.ClaimStrings{"example.com"}, claims)
+	assert.NoError(t, err)
+
+	u, err := mjwt.Inspect(accessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "at+jwt", u.Header.Typ)
+	var raw map[string]interface{}
+	assert.NoError(t, json.Unmarshal(u.Claims, &raw))
+	assert.Equal(t, "client1", raw["client_id"])
+	assert.Equal(t, "mjwt:test mjwt:test2", raw["scope"])
+	assert.Equal(t, "urn:mace:incommon:iap:silver", raw["acr"])
+	assert.Equal(t, []interface{}{"pwd", "otp"}, raw["amr"])
+	assert.Contains(t, raw, "auth_time")
+
+	_, b, err := ExtractJWTAccessToken(kStore, accessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", b.Subject)
+	assert.Equal(t, "client1", b.Claims.ClientId)
+	assert.Equal(t, []string{"pwd", "otp"}, b.Claims.Amr)
+	assert.True(t, b.Claims.Perms().Has("mjwt:test"))
+	assert.True(t, b.Claims.Perms().Has("mjwt:test2"))
+	assert.False(t, b.Claims.Perms().Has("mjwt:test3"))
+}
+
+func TestExtractJWTAccessToken(t *testing.T) {
+	t.Parallel()
+
+	kStore := mjwt.NewKeyStore()
+	s := newTestIssuer(t, "key1", kStore)
+	claims := NewJWTAccessTokenClaims("client1", NewPermStorage())
+
+	t.Run("missing typ header", func(t *testing.T) {
+		t.Parallel()
+		token, err := s.GenerateJwt("1", "test", jwt.ClaimStrings{"example.com"}, time.Minute, claims)
+		assert.NoError(t, err)
+		_, _, err = ExtractJWTAccessToken(kStore, token)
+		assert.ErrorIs(t, err, mjwt.ErrVerifyWrongType)
+	})
+
+	t.Run("media type header", func(t *testing.T) {
+		t.Parallel()
+		token, err := s.Issue(claims, mjwt.WithSubject("1"), mjwt.WithAudience("example.com"), mjwt.WithHeader("typ", "application/at+JWT"))
+		assert.NoError(t, err)
+		_, _, err = ExtractJWTAccessToken(kStore, token)
+		assert.NoError(t, err)
+	})
+
+	t.Run("missing audience", func(t *testing.T) {
+		t.Parallel()
+		token, err := CreateJWTAccessToken(s, "1", "test", nil, claims)
+		assert.NoError(t, err)
+		_, _, err = ExtractJWTAccessToken(kStore, token)
+		assert.ErrorIs(t, err, mjwt.ErrVerifyInvalidClaims)
+		assert.ErrorIs(t, err, ErrMissingRequiredClaim)
+	})
+
+	t.Run("missing client id", func(t *testing.T) {
+		t.Parallel()
+		token, err := CreateJWTAccessToken(s, "1", "test", jwt.ClaimStrings{"example.com"}, JWTAccessTokenClaims{})
+		assert.NoError(t, err)
+		_, _, err = ExtractJWTAccessToken(kStore, token)
+		assert.ErrorIs(t, err, ErrMissingClientId)
+	})
+}
+
+func TestFormatScope(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "", FormatScope(nil))
+	assert.Equal(t, "mjwt:a mjwt:b", FormatScope(ParsePermStorage("mjwt:b mjwt:a")))
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"github.com/1f349/mjwt"
+	"github.com/golang-jwt/jwt/v4"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var ErrMissingClientCertificate = errors.New("missing client certificate")
+var ErrCertificateBindingMismatch = errors.New("client certificate does not match the access token")
+
+// CertificateThumbprint calculates the base64url encoded SHA-256 thumbprint of
+// the DER encoded certificate as used by the x5t#S256 confirmation method
+func CertificateThumbprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// CreateCertificateBoundAccessToken creates an access token bound to the client
+// certificate as defined in RFC 8705
+func CreateCertificateBoundAccessToken(p *mjwt.Issuer, dur time.Duration, sub, id string, aud jwt.ClaimStrings, perms *PermStorage, cert *x509.Certificate) (string, error) {
+	return p.GenerateJwt(sub, id, aud, dur, AccessTokenClaims{
+		Perms:        perms,
+		Confirmation: &Confirmation{CertificateThumbprint: CertificateThumbprint(cert)},
+	})
+}
+
+// CheckCertificateBinding checks the access token is bound to the client
+// certificate
+func CheckCertificateBinding(claims AccessTokenClaims, cert *x509.Certificate) error {
+	if claims.Confirmation == nil || claims.Confirmation.CertificateThumbprint == "" {
+		return mjwt.NewVerifyError(mjwt.ReasonInvalidClaims, ErrCertificateBindingMismatch)
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.Confirmation.CertificateThumbprint), []byte(CertificateThumbprint(cert))) != 1 {
+		return mjwt.NewVerifyError(mjwt.ReasonInvalidClaims, ErrCertificateBindingMismatch)
+	}
+	return nil
+}
+
+// VerifyCertificateBoundRequest verifies the bearer access token from the
+// "Authorization" header and checks it is bound to the client certificate of
+// the TLS connection
+func VerifyCertificateBoundRequest(ks *mjwt.KeyStore, req *http.Request) (*jwt.Token, mjwt.BaseTypeClaims[AccessTokenClaims], error) {
+	var b mjwt.BaseTypeClaims[AccessTokenClaims]
+	if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+		return nil, b, mjwt.NewVerifyError(mjwt.ReasonInvalidClaims, ErrMissingClientCertificate)
+	}
+	accessToken, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return nil, b, mjwt.NewVerifyError(mjwt.ReasonMalformed, errors.New("missing bearer token"))
+	}
+
+	tok, b, err := extractBoundAccessToken(ks, accessToken)
+	if err != nil {
+		return nil, b, err
+	}
+	if err := CheckCertificateBinding(b.Claims, req.TLS.PeerCertificates[0]); err != nil {
+		return nil, b, err
+	}
+	return tok, b, nil
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"github.com/1f349/mjwt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "MJWT Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return &testCA{cert: cert, key: key}
+}
+
+func (c *testCA) issueClient(t *testing.T, name string, serial int64) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, c.cert, &key.PublicKey, c.key)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}
+}
+
+func TestVerifyCertificateBoundRequest(t *testing.T) {
+	t.Parallel()
+
+	kStore := mjwt.NewKeyStore()
+	s := newTestIssuer(t, "key1", kStore)
+
+	ca := newTestCA(t)
+	clientCert := ca.issueClient(t, "service-a", 2)
+	otherCert := ca.issueClient(t, "service-b", 3)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, b, err := VerifyCertificateBoundRequest(kStore, req)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		_, _ = rw.Write([]byte(b.Subject))
+	}))
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	call := func(cert tls.Certificate, token string) (int, string) {
+		c := srv.Client()
+		tr := c.Transport.(*http.Transport).Clone()
+		tr.TLSClientConfig.Certificates = []tls.Certificate{cert}
+		c = &http.Client{Transport: tr}
+
+		req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+		assert.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := c.Do(req)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	accessToken, err := CreateCertificateBoundAccessToken(s, time.Minute, "service-a", "test", nil, ParsePermStorage("mjwt:test"), clientCert.Leaf)
+	assert.NoError(t, err)
+
+	code, body := call(clientCert, accessToken)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "service-a", body)
+
+	code, body = call(otherCert, accessToken)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Contains(t, body, ErrCertificateBindingMismatch.Error())
+
+	// bound tokens cannot be used as plain bearer tokens
+	_, _, err = mjwt.ExtractClaims[AccessTokenClaims](kStore, accessToken)
+	assert.ErrorIs(t, err, ErrProofOfPossessionRequired)
+
+	bearer, err := CreateAccessToken(s, "service-a", "test", nil, NewPermStorage())
+	assert.NoError(t, err)
+	code, _ = call(clientCert, bearer)
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
+
+func TestVerifyCertificateBoundRequest_NoTLS(t *testing.T) {
+	t.Parallel()
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	_, _, err := VerifyCertificateBoundRequest(mjwt.NewKeyStore(), req)
+	assert.ErrorIs(t, err, ErrMissingClientCertificate)
+}
@@ -6,6 +6,14 @@ import (
 	"time"
 )
 
+// TokenPair contains a signed access and refresh token along with their IDs
+type TokenPair struct {
+	AccessToken    string
+	AccessTokenID  string
+	RefreshToken   string
+	RefreshTokenID string
+}
+
 // CreateTokenPair creates an access and refresh token pair using the default
 // 15 minute and 7 day durations respectively
 func CreateTokenPair(p *mjwt.Issuer, sub, id, rId string, aud, rAud jwt.ClaimStrings, perms *PermStorage) (string, string, error) {
@@ -15,13 +23,43 @@ func CreateTokenPair(p *mjwt.Issuer, sub, id, rId string, aud, rAud jwt.ClaimStr
 // CreateTokenPairWithDuration creates an access and refresh token pair using
 // custom durations for the access and refresh tokens
 func CreateTokenPairWithDuration(p *mjwt.Issuer, accessDur, refreshDur time.Duration, sub, id, rId string, aud, rAud jwt.ClaimStrings, perms *PermStorage) (string, string, error) {
-	accessToken, err := CreateAccessTokenWithDuration(p, accessDur, sub, id, aud, perms)
+	pair, err := GenerateTokenPair(p, accessDur, refreshDur, sub, id, rId, aud, rAud, perms)
 	if err != nil {
 		return "", "", err
 	}
+	return pair.AccessToken, pair.RefreshToken, nil
+}
+
+// GenerateTokenPair creates an access and refresh token pair using custom
+// durations for the access and refresh tokens. Empty IDs are generated by the
+// Issuer and are returned in the TokenPair.
+func GenerateTokenPair(p *mjwt.Issuer, accessDur, refreshDur time.Duration, sub, id, rId string, aud, rAud jwt.ClaimStrings, perms *PermStorage) (*TokenPair, error) {
+	var err error
+	if id == "" {
+		id, err = p.NewID()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if rId == "" {
+		rId, err = p.NewID()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	accessToken, err := CreateAccessTokenWithDuration(p, accessDur, sub, id, aud, perms)
+	if err != nil {
+		return nil, err
+	}
 	refreshToken, err := CreateRefreshTokenWithDuration(p, refreshDur, sub, rId, id, rAud)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
-	return accessToken, refreshToken, nil
+	return &TokenPair{
+		AccessToken:    accessToken,
+		AccessTokenID:  id,
+		RefreshToken:   refreshToken,
+		RefreshTokenID: rId,
+	}, nil
 }
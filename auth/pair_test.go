@@ -5,6 +5,7 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestCreateTokenPair(t *testing.T) {
@@ -34,3 +35,29 @@ func TestCreateTokenPair(t *testing.T) {
 	assert.Equal(t, "1", b2.Subject)
 	assert.Equal(t, "test2", b2.ID)
 }
+
+func TestGenerateTokenPair(t *testing.T) {
+	t.Parallel()
+
+	ps := NewPermStorage()
+	ps.Set("mjwt:test")
+
+	kStore := mjwt.NewKeyStore()
+	s, err := mjwt.NewIssuerWithKeyStore("mjwt.test", "key3", jwt.SigningMethodRS512, kStore, mjwt.WithIDGenerator(mjwt.ULIDGenerator))
+	assert.NoError(t, err)
+
+	pair, err := GenerateTokenPair(s, time.Minute, time.Hour, "1", "", "", nil, nil, ps)
+	assert.NoError(t, err)
+	assert.Len(t, pair.AccessTokenID, 26)
+	assert.Len(t, pair.RefreshTokenID, 26)
+	assert.NotEqual(t, pair.AccessTokenID, pair.RefreshTokenID)
+
+	_, b, err := mjwt.ExtractClaims[AccessTokenClaims](kStore, pair.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, pair.AccessTokenID, b.ID)
+
+	_, b2, err := mjwt.ExtractClaims[RefreshTokenClaims](kStore, pair.RefreshToken)
+	assert.NoError(t, err)
+	assert.Equal(t, pair.RefreshTokenID, b2.ID)
+	assert.Equal(t, pair.AccessTokenID, b2.Claims.AccessTokenId)
+}
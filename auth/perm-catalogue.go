@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"errors"
+	"github.com/1f349/mjwt"
+	"github.com/golang-jwt/jwt/v4"
+	"gopkg.in/yaml.v3"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrUnknownPerm = errors.New("unknown permission")
+
+// UnknownPermsError lists the permissions which are not in a PermCatalogue
+type UnknownPermsError struct {
+	Perms []string
+}
+
+func (u *UnknownPermsError) Error() string {
+	return ErrUnknownPerm.Error() + ": " + strings.Join(u.Perms, " ")
+}
+
+func (u *UnknownPermsError) Unwrap() error { return ErrUnknownPerm }
+
+// PermCatalogue contains the known permissions and their descriptions,
+// catalogue entries may use the same wildcards as Grants
+type PermCatalogue struct {
+	perms map[string]string
+}
+
+// NewPermCatalogue creates an empty PermCatalogue
+func NewPermCatalogue() *PermCatalogue {
+	return &PermCatalogue{perms: make(map[string]string)}
+}
+
+// LoadPermCatalogue reads a YAML mapping of permissions to descriptions
+//
+//	files:read: Read files
+//	files:write: Create and update files
+//	users:*:profile: Manage a user profile
+func LoadPermCatalogue(r io.Reader) (*PermCatalogue, error) {
+	c := NewPermCatalogue()
+	if err := yaml.NewDecoder(r).Decode(&c.perms); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if c.perms == nil {
+		c.perms = make(map[string]string)
+	}
+	return c, nil
+}
+
+// Register adds a known permission
+func (c *PermCatalogue) Register(perm, description string) {
+	c.perms[perm] = description
+}
+
+// Description outputs the description of a registered permission
+func (c *PermCatalogue) Description(perm string) (string, bool) {
+	d, ok := c.perms[perm]
+	return d, ok
+}
+
+// Perms outputs the sorted registered permissions
+func (c *PermCatalogue) Perms() []string {
+	a := make([]string, 0, len(c.perms))
+	for i := range c.perms {
+		a = append(a, i)
+	}
+	sort.Strings(a)
+	return a
+}
+
+// Known outputs true if the permission matches a registered permission.
+// Wildcard permissions are known if they match at least one registered
+// permission and template placeholders match any value.
+func (c *PermCatalogue) Known(perm string) bool {
+	if _, ok := c.perms[perm]; ok {
+		return true
+	}
+	perm = templateAsPattern(perm)
+	for i := range c.perms {
+		if permOverlaps(i, perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// templateAsPattern replaces template placeholders with "*"
+func templateAsPattern(perm string) string {
+	if !IsPermTemplate(perm) {
+		return perm
+	}
+	v, ok := expandPermTemplate(perm, PermTemplateData{}, "*")
+	if !ok {
+		return perm
+	}
+	return v
+}
+
+// Unknown outputs the sorted allowed and denied permissions which are not
+// known, denied permissions include the DenyPrefix
+func (c *PermCatalogue) Unknown(ps *PermStorage) []string {
+	var a []string
+	for _, i := range ps.Dump() {
+		if !c.Known(strings.TrimPrefix(i, DenyPrefix)) {
+			a = append(a, i)
+		}
+	}
+	return a
+}
+
+// Validate outputs an UnknownPermsError if the PermStorage contains unknown
+// permissions
+func (c *PermCatalogue) Validate(ps *PermStorage) error {
+	if a := c.Unknown(ps); len(a) > 0 {
+		return &UnknownPermsError{Perms: a}
+	}
+	return nil
+}
+
+// PermReport lists unknown permissions and registered permissions which are
+// never granted
+type PermReport struct {
+	Unknown      []string
+	NeverGranted []string
+}
+
+// Report checks the permission sets, such as the permissions of each role or
+// client, against the catalogue
+func (c *PermCatalogue) Report(grants ...*PermStorage) PermReport {
+	var r PermReport
+	unknown := make(map[string]struct{})
+	granted := make(map[string]struct{})
+	for _, ps := range grants {
+		for _, i := range c.Unknown(ps) {
+			unknown[i] = struct{}{}
+		}
+		for i := range ps.values {
+			pattern := templateAsPattern(i)
+			for j := range c.perms {
+				if permOverlaps(pattern, j) {
+					granted[j] = struct{}{}
+				}
+			}
+		}
+	}
+	for i := range unknown {
+		r.Unknown = append(r.Unknown, i)
+	}
+	for _, i := range c.Perms() {
+		if _, ok := granted[i]; !ok {
+			r.NeverGranted = append(r.NeverGranted, i)
+		}
+	}
+	sort.Strings(r.Unknown)
+	return r
+}
+
+// CreateAccessTokenWithCatalogue validates the permissions against the
+// catalogue and then creates an access token with a custom duration
+func CreateAccessTokenWithCatalogue(p *mjwt.Issuer, c *PermCatalogue, dur time.Duration, sub, id string, aud jwt.ClaimStrings, perms *PermStorage) (string, error) {
+	if err := c.Validate(perms); err != nil {
+		return "", err
+	}
+	return CreateAccessTokenWithDuration(p, dur, sub, id, aud, perms)
+}
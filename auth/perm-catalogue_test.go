@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"github.com/1f349/mjwt"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testCatalogue = `
+files:read: Read files
+files:write: Create and update files
+files:delete: Delete files
+users:*:profile: Manage a user profile
+admin:audit: Read the audit log
+`
+
+func TestPermCatalogue_Validate(t *testing.T) {
+	t.Parallel()
+	c, err := LoadPermCatalogue(strings.NewReader(testCatalogue))
+	assert.NoError(t, err)
+	d, ok := c.Description("files:read")
+	assert.True(t, ok)
+	assert.Equal(t, "Read files", d)
+	assert.Len(t, c.Perms(), 5)
+
+	assert.True(t, c.Known("files:read"))
+	assert.True(t, c.Known("files:*"))
+	assert.True(t, c.Known("users:alice:profile"))
+	assert.True(t, c.Known("users:{sub}:profile"))
+	assert.False(t, c.Known("files:raed"))
+	assert.False(t, c.Known("posts:*"))
+
+	assert.NoError(t, c.Validate(ParsePermStorage("files:* !files:delete users:{sub}:profile")))
+	err = c.Validate(ParsePermStorage("files:raed files:write !file:delete"))
+	assert.ErrorIs(t, err, ErrUnknownPerm)
+	assert.EqualError(t, err, "unknown permission: !file:delete files:raed")
+}
+
+func TestPermCatalogue_Report(t *testing.T) {
+	t.Parallel()
+	c, err := LoadPermCatalogue(strings.NewReader(testCatalogue))
+	assert.NoError(t, err)
+
+	r := c.Report(ParsePermStorage("files:read files:write"), ParsePermStorage("users:{sub}:profile posts:read !admni:*"))
+	assert.Equal(t, []string{"!admni:*", "posts:read"}, r.Unknown)
+	assert.Equal(t, []string{"admin:audit", "files:delete"}, r.NeverGranted)
+
+	r = c.Report()
+	assert.Nil(t, r.Unknown)
+	assert.Len(t, r.NeverGranted, 5)
+}
+
+func TestCreateAccessTokenWithCatalogue(t *testing.T) {
+	t.Parallel()
+
+	kStore := mjwt.NewKeyStore()
+	s := newTestIssuer(t, "key1", kStore)
+	c := NewPermCatalogue()
+	c.Register("files:read", "Read files")
+
+	_, err := CreateAccessTokenWithCatalogue(s, c, time.Minute, "1", "", nil, ParsePermStorage("files:read"))
+	assert.NoError(t, err)
+	_, err = CreateAccessTokenWithCatalogue(s, c, time.Minute, "1", "", nil, ParsePermStorage("files:write"))
+	var unknown *UnknownPermsError
+	assert.ErrorAs(t, err, &unknown)
+	assert.Equal(t, []string{"files:write"}, unknown.Perms)
+}
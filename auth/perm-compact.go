@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+var ErrPermNotCompactable = errors.New("permission cannot be compact encoded")
+var ErrInvalidCompactPerms = errors.New("invalid compact permissions")
+
+// compactNode is a trie node used to encode permissions in the compact form
+type compactNode struct {
+	children map[string]*compactNode
+	end      bool
+}
+
+func (n *compactNode) child(seg string) *compactNode {
+	if n.children == nil {
+		n.children = make(map[string]*compactNode)
+	}
+	c, ok := n.children[seg]
+	if !ok {
+		c = &compactNode{}
+		n.children[seg] = c
+	}
+	return c
+}
+
+// EncodeCompact outputs the permissions as a prefix tree where permissions
+// sharing leading segments are grouped in brackets, an empty item marks the
+// prefix itself as a permission. For example "files:read files:write users"
+// is encoded as "files(read,write),users" and "files files:read" as
+// "files(,read)". ErrPermNotCompactable is returned if a permission is empty,
+// has an empty segment or contains "(", ")" or ",".
+func (p *PermStorage) EncodeCompact() (string, error) {
+	root := &compactNode{}
+	for _, i := range p.Dump() {
+		if i == "" || strings.ContainsAny(i, "(),") {
+			return "", ErrPermNotCompactable
+		}
+		n := root
+		for _, seg := range strings.Split(i, PermSeparator) {
+			if seg == "" {
+				return "", ErrPermNotCompactable
+			}
+			n = n.child(seg)
+		}
+		n.end = true
+	}
+	var sb strings.Builder
+	root.encode(&sb)
+	return sb.String(), nil
+}
+
+func (n *compactNode) encode(sb *strings.Builder) {
+	keys := make([]string, 0, len(n.children))
+	for k := range n.children {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	first := !n.end
+	for _, k := range keys {
+		if !first {
+			sb.WriteByte(',')
+		}
+		first = false
+
+		// join chains of single children into one item
+		c := n.children[k]
+		sb.WriteString(k)
+		for !c.end && len(c.children) == 1 {
+			for k2, c2 := range c.children {
+				sb.WriteString(PermSeparator)
+				sb.WriteString(k2)
+				c = c2
+			}
+		}
+		if len(c.children) > 0 {
+			sb.WriteByte('(')
+			c.encode(sb)
+			sb.WriteByte(')')
+		}
+	}
+}
+
+// ParseCompactPermStorage decodes permissions encoded with EncodeCompact
+func ParseCompactPermStorage(s string) (*PermStorage, error) {
+	ps := NewPermStorage()
+	if s == "" {
+		return ps, nil
+	}
+	d := compactDecoder{s: s, ps: ps}
+	if err := d.list("", false); err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.s) {
+		return nil, ErrInvalidCompactPerms
+	}
+	return ps, nil
+}
+
+type compactDecoder struct {
+	s   string
+	pos int
+	ps  *PermStorage
+}
+
+// list decodes comma separated items until the end of the string or a closing
+// bracket, nested lists allow an empty item which sets the prefix itself
+func (d *compactDecoder) list(prefix string, nested bool) error {
+	for {
+		start := d.pos
+		for d.pos < len(d.s) && !strings.ContainsRune("(),", rune(d.s[d.pos])) {
+			d.pos++
+		}
+		name := d.s[start:d.pos]
+		perm := name
+		if prefix != "" {
+			perm = prefix + PermSeparator + name
+		}
+
+		switch {
+		case d.pos < len(d.s) && d.s[d.pos] == '(':
+			if name == "" {
+				return ErrInvalidCompactPerms
+			}
+			d.pos++
+			if err := d.list(perm, true); err != nil {
+				return err
+			}
+			if d.pos >= len(d.s) || d.s[d.pos] != ')' {
+				return ErrInvalidCompactPerms
+			}
+			d.pos++
+		case name == "" && nested:
+			d.ps.Set(prefix)
+		case name == "":
+			return ErrInvalidCompactPerms
+		default:
+			d.ps.Set(perm)
+		}
+
+		if d.pos < len(d.s) && d.s[d.pos] == ',' {
+			d.pos++
+			continue
+		}
+		return nil
+	}
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/1f349/mjwt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestPermStorage_EncodeCompact(t *testing.T) {
+	t.Parallel()
+	for _, i := range []struct {
+		perms, compact string
+	}{
+		{"", ""},
+		{"users", "users"},
+		{"files:read files:write users:*", "files(read,write),users:*"},
+		{"files files:read", "files(,read)"},
+		{"a:b:c:d a:b:c:e a:x", "a(b:c(d,e),x)"},
+		{"files:* !files:delete", "!files:delete,files:*"},
+		{"a:b a:b:c a:b:d", "a:b(,c,d)"},
+	} {
+		ps := ParsePermStorage(i.perms)
+		s, err := ps.EncodeCompact()
+		assert.NoError(t, err)
+		assert.Equal(t, i.compact, s)
+
+		out, err := ParseCompactPermStorage(s)
+		assert.NoError(t, err)
+		assert.Equal(t, ps.Dump(), out.Dump())
+	}
+
+	for _, i := range []string{"a(b)", "a,b", "a::b"} {
+		_, err := ParsePermStorage(i).EncodeCompact()
+		assert.ErrorIs(t, err, ErrPermNotCompactable)
+	}
+	for _, i := range []string{"a(", "a(b", "a)", "(a)", ",a", "a,,b", "a(b))"} {
+		_, err := ParseCompactPermStorage(i)
+		assert.ErrorIs(t, err, ErrInvalidCompactPerms, i)
+	}
+}
+
+func TestAccessTokenClaims_CompactPerms(t *testing.T) {
+	t.Parallel()
+
+	kStore := mjwt.NewKeyStore()
+	s := newTestIssuer(t, "key1", kStore)
+
+	ps := NewPermStorage()
+	for i := range 200 {
+		ps.Set(fmt.Sprintf("service:resource%d:read", i))
+		ps.Set(fmt.Sprintf("service:resource%d:write", i))
+	}
+	full, err := json.Marshal(AccessTokenClaims{Perms: ps})
+	assert.NoError(t, err)
+	compact, err := json.Marshal(AccessTokenClaims{Perms: ps, CompactPerms: true})
+	assert.NoError(t, err)
+	assert.Less(t, len(compact)*2, len(full))
+
+	token, err := s.GenerateJwt("1", "", nil, time.Minute, AccessTokenClaims{Perms: ps, CompactPerms: true})
+	assert.NoError(t, err)
+	_, b, err := mjwt.ExtractClaims[AccessTokenClaims](kStore, token)
+	assert.NoError(t, err)
+	assert.Equal(t, ps.Dump(), b.Claims.Perms.Dump())
+
+	// permissions which cannot be compact encoded use the array form
+	b2, err := json.Marshal(AccessTokenClaims{Perms: ParsePermStorage("a(b)"), CompactPerms: true})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"per":["a(b)"]}`, string(b2))
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"encoding/json"
+)
+
+// FrozenPermStorage is an immutable PermStorage which is safe to share between
+// goroutines. The indexes used by Grants are built when the storage is
+// created and changes are made with the copy-on-write With and Without
+// methods.
+type FrozenPermStorage struct {
+	ps *PermStorage
+}
+
+// Freeze outputs an immutable copy of the PermStorage
+func (p *PermStorage) Freeze() *FrozenPermStorage {
+	return newFrozenPermStorage(p.Clone())
+}
+
+// ParseFrozenPermStorage parses space separated permissions into an immutable
+// FrozenPermStorage
+func ParseFrozenPermStorage(perms string) *FrozenPermStorage {
+	return newFrozenPermStorage(ParsePermStorage(perms))
+}
+
+// newFrozenPermStorage takes ownership of ps and builds the indexes
+func newFrozenPermStorage(ps *PermStorage) *FrozenPermStorage {
+	ps.index = newPermIndex(ps.values)
+	ps.denyIndex = newDenyIndex(ps.denied)
+	return &FrozenPermStorage{ps: ps}
+}
+
+// With outputs a copy with the permissions added, permissions starting with
+// DenyPrefix are added as denied permissions
+func (f *FrozenPermStorage) With(perms ...string) *FrozenPermStorage {
+	ps := f.ps.Clone()
+	for _, i := range perms {
+		ps.Set(i)
+	}
+	return newFrozenPermStorage(ps)
+}
+
+// Without outputs a copy with the permissions removed, permissions starting
+// with DenyPrefix remove denied permissions
+func (f *FrozenPermStorage) Without(perms ...string) *FrozenPermStorage {
+	ps := f.ps.Clone()
+	for _, i := range perms {
+		ps.Clear(i)
+	}
+	return newFrozenPermStorage(ps)
+}
+
+// Thaw outputs a mutable copy of the permissions
+func (f *FrozenPermStorage) Thaw() *PermStorage { return f.ps.Clone() }
+
+func (f *FrozenPermStorage) Has(perm string) bool               { return f.ps.Has(perm) }
+func (f *FrozenPermStorage) Grants(perm string) bool            { return f.ps.Grants(perm) }
+func (f *FrozenPermStorage) IsDenied(perm string) bool          { return f.ps.IsDenied(perm) }
+func (f *FrozenPermStorage) OneOf(o *PermStorage) bool          { return f.ps.OneOf(o) }
+func (f *FrozenPermStorage) AllOf(o *PermStorage) bool          { return f.ps.AllOf(o) }
+func (f *FrozenPermStorage) Search(v string) []string           { return f.ps.Search(v) }
+func (f *FrozenPermStorage) Filter(match []string) *PermStorage { return f.ps.Filter(match) }
+func (f *FrozenPermStorage) Dump() []string                     { return f.ps.Dump() }
+func (f *FrozenPermStorage) Len() int                           { return f.ps.Len() }
+
+func (f *FrozenPermStorage) MarshalJSON() ([]byte, error) { return json.Marshal(f.ps.Dump()) }
+
+func (f *FrozenPermStorage) MarshalYAML() (interface{}, error) { return f.ps.Dump(), nil }
+
+// UnmarshalJSON decodes the permissions, this must not be called after the
+// FrozenPermStorage is shared
+func (f *FrozenPermStorage) UnmarshalJSON(bytes []byte) error {
+	ps := NewPermStorage()
+	if err := ps.UnmarshalJSON(bytes); err != nil {
+		return err
+	}
+	*f = *newFrozenPermStorage(ps)
+	return nil
+}
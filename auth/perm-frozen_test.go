@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestFrozenPermStorage(t *testing.T) {
+	t.Parallel()
+	ps := ParsePermStorage("files:* !files:delete users:read")
+	f := ps.Freeze()
+
+	// the frozen copy is not changed by the original
+	ps.Set("admin:*")
+	assert.False(t, f.Grants("admin:users"))
+
+	assert.True(t, f.Grants("files:read"))
+	assert.False(t, f.Grants("files:delete"))
+	assert.True(t, f.Has("users:read"))
+	assert.Equal(t, 3, f.Len())
+
+	g := f.With("admin:*", "!users:read").Without("!files:delete")
+	assert.True(t, g.Grants("admin:users"))
+	assert.True(t, g.Grants("files:delete"))
+	assert.False(t, g.Has("users:read"))
+	assert.Equal(t, []string{"!files:delete", "files:*", "users:read"}, f.Dump())
+
+	thawed := g.Thaw()
+	thawed.Clear("admin:*")
+	assert.True(t, g.Grants("admin:users"))
+
+	b, err := json.Marshal(f)
+	assert.NoError(t, err)
+	assert.Equal(t, `["!files:delete","files:*","users:read"]`, string(b))
+	var out FrozenPermStorage
+	assert.NoError(t, json.Unmarshal(b, &out))
+	assert.Equal(t, f.Dump(), out.Dump())
+	assert.False(t, out.Grants("files:delete"))
+}
+
+func TestFrozenPermStorage_Concurrent(t *testing.T) {
+	t.Parallel()
+	f := ParseFrozenPermStorage("files:* !files:delete users:{sub}:read")
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 100 {
+				assert.True(t, f.Grants("files:read"))
+				assert.False(t, f.Grants("files:delete"))
+				_ = f.With(fmt.Sprintf("extra:%d:%d", i, j))
+				_ = f.Dump()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 3, f.Len())
+}
+
+func benchmarkPerms() *PermStorage {
+	ps := NewPermStorage()
+	for i := range 1000 {
+		ps.Set(fmt.Sprintf("service%d:resource%d:read", i%10, i))
+		ps.Set(fmt.Sprintf("service%d:admin%d:*", i%10, i))
+	}
+	ps.Deny("service1:admin1:delete")
+	return ps
+}
+
+func BenchmarkPermStorage_Has(b *testing.B) {
+	ps := benchmarkPerms()
+	b.ResetTimer()
+	for range b.N {
+		ps.Has("service2:resource502:read")
+	}
+}
+
+func BenchmarkFrozenPermStorage_Has(b *testing.B) {
+	f := benchmarkPerms().Freeze()
+	b.ResetTimer()
+	for range b.N {
+		f.Has("service2:resource502:read")
+	}
+}
+
+func BenchmarkPermStorage_GrantsWildcard(b *testing.B) {
+	ps := benchmarkPerms()
+	ps.Grants("warm:index")
+	b.ResetTimer()
+	for range b.N {
+		ps.Grants("service2:admin502:users:delete")
+	}
+}
+
+func BenchmarkFrozenPermStorage_GrantsWildcard(b *testing.B) {
+	f := benchmarkPerms().Freeze()
+	b.ResetTimer()
+	for range b.N {
+		f.Grants("service2:admin502:users:delete")
+	}
+}
+
+func BenchmarkFrozenPermStorage_GrantsParallel(b *testing.B) {
+	f := benchmarkPerms().Freeze()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			f.Grants("service2:admin502:users:delete")
+		}
+	})
+}
+
+func BenchmarkPermStorage_Set(b *testing.B) {
+	ps := benchmarkPerms()
+	b.ResetTimer()
+	for i := range b.N {
+		ps.Set(fmt.Sprintf("new:%d", i%100))
+		ps.Grants("service2:admin502:users:delete")
+	}
+}
+
+func BenchmarkFrozenPermStorage_With(b *testing.B) {
+	f := benchmarkPerms().Freeze()
+	b.ResetTimer()
+	for i := range b.N {
+		f = f.With(fmt.Sprintf("new:%d", i%100))
+		f.Grants("service2:admin502:users:delete")
+	}
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"github.com/becheran/wildmatch-go"
+	"strings"
+)
+
+// PermSeparator separates the levels of a hierarchical permission
+const PermSeparator = ":"
+
+// permIndex is a trie of permission patterns split on PermSeparator
+//
+// A "*" segment matches any single segment, a trailing "*" segment matches one
+// or more remaining segments and other segments containing "*" or "?" are
+// matched against a single segment using wildmatch.
+type permIndex struct {
+	root *permNode
+}
+
+type permNode struct {
+	children map[string]*permNode
+	any      *permNode
+	patterns []permPattern
+
+	// end is true if a pattern finishes at this node, rest is true if a
+	// trailing "*" follows this node
+	end  bool
+	rest bool
+}
+
+type permPattern struct {
+	seg   string
+	match *wildmatch.WildMatch
+	node  *permNode
+}
+
+func newPermIndex(perms map[string]struct{}) *permIndex {
+	idx := &permIndex{root: &permNode{}}
+	for i := range perms {
+		idx.add(i)
+	}
+	return idx
+}
+
+// newDenyIndex creates an index of the denied permissions, placeholders of
+// unresolved templates match any value
+func newDenyIndex(denied map[string]struct{}) *permIndex {
+	idx := &permIndex{root: &permNode{}}
+	for i := range denied {
+		idx.add(denyTemplatePattern(i))
+	}
+	return idx
+}
+
+func isPermPattern(seg string) bool {
+	return strings.ContainsAny(seg, "*?")
+}
+
+func (idx *permIndex) add(perm string) {
+	segs := strings.Split(perm, PermSeparator)
+	n := idx.root
+	for i, seg := range segs {
+		switch {
+		case seg == "*" && i == len(segs)-1:
+			n.rest = true
+			return
+		case seg == "*":
+			if n.any == nil {
+				n.any = &permNode{}
+			}
+			n = n.any
+		case isPermPattern(seg):
+			n = n.pattern(seg)
+		default:
+			if n.children == nil {
+				n.children = make(map[string]*permNode)
+			}
+			c, ok := n.children[seg]
+			if !ok {
+				c = &permNode{}
+				n.children[seg] = c
+			}
+			n = c
+		}
+	}
+	n.end = true
+}
+
+func (n *permNode) pattern(seg string) *permNode {
+	for _, p := range n.patterns {
+		if p.seg == seg {
+			return p.node
+		}
+	}
+	c := &permNode{}
+	n.patterns = append(n.patterns, permPattern{seg: seg, match: wildmatch.NewWildMatch(seg), node: c})
+	return c
+}
+
+// match outputs true if any pattern in the index matches the permission
+func (idx *permIndex) match(perm string) bool {
+	return idx.root.match(strings.Split(perm, PermSeparator))
+}
+
+func (n *permNode) match(segs []string) bool {
+	if len(segs) == 0 {
+		return n.end
+	}
+	if n.rest {
+		return true
+	}
+	seg, next := segs[0], segs[1:]
+	if c, ok := n.children[seg]; ok && c.match(next) {
+		return true
+	}
+	if n.any != nil && n.any.match(next) {
+		return true
+	}
+	for _, p := range n.patterns {
+		if p.match.IsMatch(seg) && p.node.match(next) {
+			return true
+		}
+	}
+	return false
+}
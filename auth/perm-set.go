@@ -0,0 +1,304 @@
+package auth
+
+import (
+	"errors"
+	"github.com/becheran/wildmatch-go"
+	"strings"
+)
+
+var ErrPermNotRepresentable = errors.New("permission set cannot be represented")
+
+// permPatternSegs splits a permission into segments, a trailing "*" segment is
+// removed and reported as rest
+func permPatternSegs(perm string) (segs []string, rest bool) {
+	segs = strings.Split(perm, PermSeparator)
+	if segs[len(segs)-1] == "*" {
+		return segs[:len(segs)-1], true
+	}
+	return segs, false
+}
+
+func joinPermSegs(segs []string, rest bool) string {
+	if rest {
+		segs = append(segs[:len(segs):len(segs)], "*")
+	}
+	return strings.Join(segs, PermSeparator)
+}
+
+// segCovers outputs true if segment a matches every value segment b matches
+func segCovers(a, b string) bool {
+	switch {
+	case a == "*" || a == b:
+		return true
+	case isPermPattern(a):
+		return !isPermPattern(b) && wildmatch.NewWildMatch(a).IsMatch(b)
+	}
+	return false
+}
+
+// segOverlaps outputs true if segments a and b can match the same value, two
+// different wildmatch segments are assumed to overlap
+func segOverlaps(a, b string) bool {
+	switch {
+	case a == b, isPermPattern(a) && isPermPattern(b):
+		return true
+	case isPermPattern(a):
+		return wildmatch.NewWildMatch(a).IsMatch(b)
+	case isPermPattern(b):
+		return wildmatch.NewWildMatch(b).IsMatch(a)
+	}
+	return false
+}
+
+// segMeet outputs a segment matching the values matched by both a and b
+func segMeet(a, b string) (string, bool) {
+	switch {
+	case segCovers(a, b):
+		return b, true
+	case segCovers(b, a):
+		return a, true
+	}
+	return "", false
+}
+
+// permCovers outputs true if the permission pattern a grants every permission
+// granted by pattern b
+func permCovers(a, b string) bool {
+	as, ar := permPatternSegs(a)
+	bs, br := permPatternSegs(b)
+	if len(as) > len(bs) {
+		return false
+	}
+	for i := range as {
+		if !segCovers(as[i], bs[i]) {
+			return false
+		}
+	}
+	if ar {
+		return len(bs) > len(as) || br
+	}
+	return len(bs) == len(as) && !br
+}
+
+// permOverlaps outputs true if the permission patterns a and b can both grant
+// the same permission
+func permOverlaps(a, b string) bool {
+	as, ar := permPatternSegs(a)
+	bs, br := permPatternSegs(b)
+	for i := range min(len(as), len(bs)) {
+		if !segOverlaps(as[i], bs[i]) {
+			return false
+		}
+	}
+	switch {
+	case len(as) == len(bs):
+		return ar == br
+	case len(as) < len(bs):
+		return ar
+	}
+	return br
+}
+
+// permMeet outputs a permission pattern granting the permissions granted by
+// both a and b, false is returned if there are none or the intersection of
+// two different wildmatch segments is required
+func permMeet(a, b string) (string, bool) {
+	as, ar := permPatternSegs(a)
+	bs, br := permPatternSegs(b)
+	if len(as) > len(bs) {
+		as, ar, bs, br = bs, br, as, ar
+	}
+	out := make([]string, 0, len(bs))
+	for i := range as {
+		s, ok := segMeet(as[i], bs[i])
+		if !ok {
+			return "", false
+		}
+		out = append(out, s)
+	}
+	switch {
+	case len(as) == len(bs) && ar == br:
+		return joinPermSegs(out, ar), true
+	case len(as) < len(bs) && ar:
+		return joinPermSegs(append(out, bs[len(as):]...), br), true
+	}
+	return "", false
+}
+
+// grantsPattern outputs true if a single allowed permission covers the pattern
+// and no denied permission overlaps it. Denied permissions which are also
+// covered by a denied permission of except are ignored.
+func (p *PermStorage) grantsPattern(perm string, except *PermStorage) bool {
+	for d := range p.denied {
+		if permOverlaps(denyTemplatePattern(d), perm) && (except == nil || !except.deniesPattern(d)) {
+			return false
+		}
+	}
+	if _, ok := p.values[perm]; ok {
+		return true
+	}
+	for i := range p.values {
+		if permCovers(i, perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// deniesPattern outputs true if a denied permission covers the pattern
+func (p *PermStorage) deniesPattern(perm string) bool {
+	for d := range p.denied {
+		if permCovers(denyTemplatePattern(d), perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// Len outputs the number of allowed and denied entries
+func (p *PermStorage) Len() int { return len(p.values) + len(p.denied) }
+
+// Clone outputs a copy of the PermStorage
+func (p *PermStorage) Clone() *PermStorage {
+	out := NewPermStorage()
+	for i := range p.values {
+		out.values[i] = struct{}{}
+	}
+	for i := range p.denied {
+		out.denied[i] = struct{}{}
+	}
+	return out
+}
+
+// AllOf outputs true if every allowed permission of o is granted by p, the
+// entries of o are treated as patterns so "files:*" requires p to grant all
+// of "files:*"
+func (p *PermStorage) AllOf(o *PermStorage) bool {
+	for i := range o.values {
+		if !p.grantsPattern(i, o) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsSubsetOf outputs true if every permission granted by p is granted by o
+func (p *PermStorage) IsSubsetOf(o *PermStorage) bool { return o.AllOf(p) }
+
+// Equal outputs true if p and o grant the same permissions
+func (p *PermStorage) Equal(o *PermStorage) bool {
+	return p.IsSubsetOf(o) && o.IsSubsetOf(p)
+}
+
+// Union outputs the permissions granted by either p or o. Denied permissions
+// are narrowed to the part the other side does not grant, so the union never
+// grants a permission denied on one side and not granted by the other.
+// ErrPermNotRepresentable is returned if a denied permission is partly granted
+// by the other side and the remaining part cannot be written as a pattern.
+func (p *PermStorage) Union(o *PermStorage) (*PermStorage, error) {
+	out := NewPermStorage()
+	for i := range p.values {
+		out.values[i] = struct{}{}
+	}
+	for i := range o.values {
+		out.values[i] = struct{}{}
+	}
+	for d := range p.denied {
+		if err := out.unionDeny(d, o); err != nil {
+			return nil, err
+		}
+	}
+	for d := range o.denied {
+		if err := out.unionDeny(d, p); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// unionDeny adds the part of the denied permission d which is not granted by o
+func (p *PermStorage) unionDeny(d string, o *PermStorage) error {
+	overlaps, covered := false, false
+	for i := range o.values {
+		if permCovers(i, d) {
+			covered = true
+			break
+		}
+		if permOverlaps(i, d) {
+			overlaps = true
+		}
+	}
+	switch {
+	case covered:
+		// only the denied permissions of o remain denied
+		for od := range o.denied {
+			if !permOverlaps(od, d) {
+				continue
+			}
+			m, ok := permMeet(od, d)
+			if !ok {
+				return ErrPermNotRepresentable
+			}
+			p.denied[m] = struct{}{}
+		}
+	case overlaps:
+		return ErrPermNotRepresentable
+	default:
+		p.denied[d] = struct{}{}
+	}
+	return nil
+}
+
+// Intersect outputs the permissions granted by both p and o. Wildcard entries
+// are narrowed to the overlapping patterns, for example "files:*" and
+// "files:read" intersect to "files:read". The denied permissions of both sides
+// are kept.
+func (p *PermStorage) Intersect(o *PermStorage) *PermStorage {
+	out := NewPermStorage()
+	for i := range p.values {
+		for j := range o.values {
+			if m, ok := permMeet(i, j); ok {
+				out.values[m] = struct{}{}
+			}
+		}
+	}
+	for d := range p.denied {
+		out.denied[d] = struct{}{}
+	}
+	for d := range o.denied {
+		out.denied[d] = struct{}{}
+	}
+	return out
+}
+
+// Subtract outputs the permissions granted by p and not granted by o. Allowed
+// entries of p fully granted by o are removed and the parts of the allowed
+// entries of o which overlap the remaining entries are added as denied
+// permissions, parts which o denies itself are skipped. The result never
+// grants more than the true difference.
+func (p *PermStorage) Subtract(o *PermStorage) *PermStorage {
+	out := NewPermStorage()
+	for d := range p.denied {
+		out.denied[d] = struct{}{}
+	}
+	for i := range p.values {
+		if o.grantsPattern(i, p) {
+			continue
+		}
+		out.values[i] = struct{}{}
+		for j := range o.values {
+			if !permOverlaps(i, j) {
+				continue
+			}
+			m, ok := permMeet(i, j)
+			if !ok {
+				out.denied[j] = struct{}{}
+				continue
+			}
+			if !o.deniesPattern(m) {
+				out.denied[m] = struct{}{}
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestPermCovers(t *testing.T) {
+	t.Parallel()
+	for _, i := range []struct {
+		a, b  string
+		cover bool
+	}{
+		{"files:read", "files:read", true},
+		{"files:*", "files:read", true},
+		{"files:*", "files:read:all", true},
+		{"files:*", "files", false},
+		{"files:*", "files:*", true},
+		{"files:*", "files:*:read", true},
+		{"files:*:read", "files:*", false},
+		{"files:*:read", "files:doc:read", true},
+		{"files:doc-*", "files:doc-1", true},
+		{"files:doc-*", "files:*", false},
+		{"files:read", "files:*", false},
+		{"*", "anything:at:all", true},
+	} {
+		assert.Equal(t, i.cover, permCovers(i.a, i.b), "%s covers %s", i.a, i.b)
+	}
+}
+
+func TestPermStorage_Len(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, 0, NewPermStorage().Len())
+	assert.Equal(t, 3, ParsePermStorage("a b !c").Len())
+}
+
+func TestPermStorage_AllOf(t *testing.T) {
+	t.Parallel()
+	ps := ParsePermStorage("files:* users:read !files:delete")
+	assert.True(t, ps.AllOf(ParsePermStorage("files:read files:write users:read")))
+	assert.True(t, ps.AllOf(ParsePermStorage("files:doc:*")))
+	assert.False(t, ps.AllOf(ParsePermStorage("files:read users:write")))
+	assert.False(t, ps.AllOf(ParsePermStorage("files:delete")))
+	assert.False(t, ps.AllOf(ParsePermStorage("files:*")))
+	assert.True(t, ps.AllOf(ParsePermStorage("files:* !files:delete")))
+	assert.True(t, ps.AllOf(NewPermStorage()))
+}
+
+func TestPermStorage_IsSubsetOf(t *testing.T) {
+	t.Parallel()
+	refresh := ParsePermStorage("files:* users:read")
+	access := ParsePermStorage("files:read users:read")
+	assert.True(t, access.IsSubsetOf(refresh))
+	assert.False(t, refresh.IsSubsetOf(access))
+	assert.True(t, refresh.Equal(ParsePermStorage("users:read files:*")))
+	assert.False(t, refresh.Equal(access))
+	assert.True(t, NewPermStorage().Equal(NewPermStorage()))
+}
+
+func TestPermStorage_Union(t *testing.T) {
+	t.Parallel()
+	a := ParsePermStorage("files:* !files:delete")
+	for _, i := range []struct {
+		other, out string
+	}{
+		{"users:read", "!files:delete files:* users:read"},
+		{"files:delete", "files:* files:delete"},
+		{"files:*", "files:*"},
+		{"files:* !files:delete:*", "files:*"},
+		{"files:* !files:delete !users:*", "!files:delete !users:* files:*"},
+		{"* !files:*", "!files:delete * files:*"},
+	} {
+		out, err := a.Union(ParsePermStorage(i.other))
+		assert.NoError(t, err, i.other)
+		assert.Equal(t, strings.Fields(i.out), out.Dump(), i.other)
+	}
+
+	// the deny is narrowed to the part the other side does not grant
+	b := ParsePermStorage("files:* !files:secret:*")
+	out, err := b.Union(ParsePermStorage("files:* !files:secret:private"))
+	assert.NoError(t, err)
+	assert.True(t, out.Grants("files:secret:readme"))
+	assert.False(t, out.Grants("files:secret:private"))
+
+	// a deny which is partly granted by the other side cannot be represented
+	_, err = b.Union(ParsePermStorage("files:secret:readme"))
+	assert.ErrorIs(t, err, ErrPermNotRepresentable)
+}
+
+func TestPermStorage_Intersect(t *testing.T) {
+	t.Parallel()
+	a := ParsePermStorage("files:* users:read posts:*:read")
+	b := ParsePermStorage("files:read files:write:all users:write posts:5:* !files:write:all")
+	assert.Equal(t, []string{"!files:write:all", "files:read", "files:write:all", "posts:5:read"}, a.Intersect(b).Dump())
+	assert.True(t, a.Intersect(b).Grants("files:read"))
+	assert.False(t, a.Intersect(b).Grants("files:write:all"))
+	assert.Equal(t, []string{"files:*", "posts:*:read", "users:read"}, a.Intersect(ParsePermStorage("*:*")).Dump())
+}
+
+func TestPermStorage_Subtract(t *testing.T) {
+	t.Parallel()
+	a := ParsePermStorage("files:* users:read users:write")
+	out := a.Subtract(ParsePermStorage("files:delete users:read"))
+	assert.Equal(t, []string{"!files:delete", "files:*", "users:write"}, out.Dump())
+	assert.True(t, out.Grants("files:read"))
+	assert.False(t, out.Grants("files:delete"))
+
+	assert.Equal(t, 0, a.Subtract(a).Len())
+	assert.Equal(t, []string{"users:read"}, a.Subtract(ParsePermStorage("files:* users:write")).Dump())
+
+	// parts of the other side which it denies itself are not subtracted
+	assert.Equal(t, []string{"a:x"}, ParsePermStorage("a:x").Subtract(ParsePermStorage("a:* !a:x")).Dump())
+	out = ParsePermStorage("a:*").Subtract(ParsePermStorage("a:x:*"))
+	assert.Equal(t, []string{"!a:x:*", "a:*"}, out.Dump())
+}
+
+func TestPermStorage_Clone(t *testing.T) {
+	t.Parallel()
+	a := ParsePermStorage("a !b")
+	c := a.Clone()
+	c.Set("c")
+	assert.Equal(t, []string{"!b", "a"}, a.Dump())
+	assert.Equal(t, []string{"!b", "a", "c"}, c.Dump())
+}
@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/1f349/mjwt"
+	"github.com/golang-jwt/jwt/v4"
+	"strconv"
+	"strings"
+)
+
+var ErrMissingRawToken = errors.New("missing raw token")
+
+// PermTemplateData contains the values substituted into permission templates
+//
+// "{sub}" is replaced with Subject and "{claims.name}" is replaced with the
+// claim value, nested objects are accessed with "{claims.parent.child}".
+type PermTemplateData struct {
+	Subject string
+	Claims  map[string]interface{}
+}
+
+// PermTemplateDataFromToken decodes the subject and all claims from the
+// payload of a verified token
+func PermTemplateDataFromToken(tok *jwt.Token) (PermTemplateData, error) {
+	parts := strings.Split(tok.Raw, ".")
+	if len(parts) != 3 {
+		return PermTemplateData{}, ErrMissingRawToken
+	}
+	payload, err := jwt.DecodeSegment(parts[1])
+	if err != nil {
+		return PermTemplateData{}, err
+	}
+	dec := json.NewDecoder(strings.NewReader(string(payload)))
+	dec.UseNumber()
+	var claims map[string]interface{}
+	if err := dec.Decode(&claims); err != nil {
+		return PermTemplateData{}, err
+	}
+	sub, _ := claims["sub"].(string)
+	return PermTemplateData{Subject: sub, Claims: claims}, nil
+}
+
+// lookup outputs the string value for a placeholder name, values containing
+// separators, wildcards or whitespace are rejected so a claim cannot widen a
+// permission
+func (d PermTemplateData) lookup(name string) (string, bool) {
+	var v interface{}
+	switch {
+	case name == "sub":
+		v = d.Subject
+	case strings.HasPrefix(name, "claims."):
+		v = d.Claims
+		for _, i := range strings.Split(strings.TrimPrefix(name, "claims."), ".") {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return "", false
+			}
+			v = m[i]
+		}
+	default:
+		return "", false
+	}
+
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case json.Number:
+		s = v.String()
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		s = strconv.FormatBool(v)
+	default:
+		return "", false
+	}
+	if s == "" || strings.Contains(s, PermSeparator) || strings.ContainsAny(s, "*?{} \t\r\n") {
+		return "", false
+	}
+	return s, true
+}
+
+// IsPermTemplate outputs true if the permission contains a placeholder
+func IsPermTemplate(perm string) bool {
+	return strings.Contains(perm, "{")
+}
+
+// expandPermTemplate replaces the placeholders in the permission. If a
+// placeholder cannot be resolved the fallback is used, an empty fallback makes
+// the whole permission unresolved.
+func expandPermTemplate(perm string, data PermTemplateData, fallback string) (string, bool) {
+	var sb strings.Builder
+	for {
+		start := strings.IndexByte(perm, '{')
+		if start == -1 {
+			sb.WriteString(perm)
+			return sb.String(), true
+		}
+		end := strings.IndexByte(perm[start:], '}')
+		if end == -1 {
+			return "", false
+		}
+		sb.WriteString(perm[:start])
+		v, ok := data.lookup(perm[start+1 : start+end])
+		if !ok {
+			if fallback == "" {
+				return "", false
+			}
+			v = fallback
+		}
+		sb.WriteString(v)
+		perm = perm[start+end+1:]
+	}
+}
+
+// ResolveTemplates outputs a copy of the PermStorage with the placeholders in
+// each permission replaced using the data. Allowed permissions which cannot be
+// resolved are removed and unresolved placeholders in denied permissions are
+// replaced with "*" so the deny still applies.
+func (p *PermStorage) ResolveTemplates(data PermTemplateData) *PermStorage {
+	out := NewPermStorage()
+	for i := range p.values {
+		if v, ok := expandPermTemplate(i, data, ""); ok {
+			out.values[v] = struct{}{}
+		}
+	}
+	for i := range p.denied {
+		if v, ok := expandPermTemplate(i, data, "*"); ok {
+			out.denied[v] = struct{}{}
+		} else {
+			out.denied[denyTemplatePattern(i)] = struct{}{}
+		}
+	}
+	return out
+}
+
+// denyTemplatePattern outputs the pattern matched by a denied permission with
+// unresolved placeholders. Placeholders match any value and malformed
+// templates deny everything below the prefix, so the deny fails closed.
+func denyTemplatePattern(perm string) string {
+	if !IsPermTemplate(perm) {
+		return perm
+	}
+	if v, ok := expandPermTemplate(perm, PermTemplateData{}, "*"); ok {
+		return v
+	}
+	return perm[:strings.IndexByte(perm, '{')] + "*"
+}
+
+// ExtractAccessTokenWithTemplates verifies an access token and resolves the
+// permission templates using the subject and claims of the token
+func ExtractAccessTokenWithTemplates(ks *mjwt.KeyStore, token string) (*jwt.Token, mjwt.BaseTypeClaims[AccessTokenClaims], error) {
+	tok, b, err := mjwt.ExtractClaims[AccessTokenClaims](ks, token)
+	if err != nil {
+		return nil, b, err
+	}
+	if err := resolveClaimTemplates(tok, &b.Claims); err != nil {
+		return nil, b, err
+	}
+	return tok, b, nil
+}
+
+func resolveClaimTemplates(tok *jwt.Token, claims *AccessTokenClaims) error {
+	if claims.Perms == nil {
+		return nil
+	}
+	data, err := PermTemplateDataFromToken(tok)
+	if err != nil {
+		return mjwt.NewVerifyError(mjwt.ReasonMalformed, err)
+	}
+	claims.Perms = claims.Perms.ResolveTemplates(data)
+	return nil
+}
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"github.com/1f349/mjwt"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPermStorage_ResolveTemplates(t *testing.T) {
+	t.Parallel()
+	ps := ParsePermStorage("users:{sub}:write tenant:{claims.tenant}:* org:{claims.org.id}:read posts:read missing:{claims.nope} bad:{sub !tenant:{claims.nope}:delete")
+	data := PermTemplateData{
+		Subject: "user1",
+		Claims: map[string]interface{}{
+			"tenant": "acme",
+			"org":    map[string]interface{}{"id": float64(42)},
+		},
+	}
+	out := ps.ResolveTemplates(data)
+	assert.Equal(t, []string{"!tenant:*:delete", "org:42:read", "posts:read", "tenant:acme:*", "users:user1:write"}, out.Dump())
+	assert.True(t, out.Grants("users:user1:write"))
+	assert.False(t, out.Grants("users:user2:write"))
+	assert.True(t, out.Grants("tenant:acme:read"))
+	assert.False(t, out.Grants("tenant:acme:delete"))
+
+	// values cannot widen a permission
+	for _, i := range []string{"*", "a:b", "a b", ""} {
+		out = ParsePermStorage("users:{sub}:write").ResolveTemplates(PermTemplateData{Subject: i})
+		assert.Equal(t, 0, out.Len(), i)
+	}
+}
+
+func TestPermStorage_UnresolvedDenyTemplates(t *testing.T) {
+	t.Parallel()
+
+	kStore := mjwt.NewKeyStore()
+	s := newTestIssuer(t, "key1", kStore)
+
+	// unresolved deny templates fail closed on the default verify path
+	token, err := CreateAccessToken(s, "alice", "", nil, ParsePermStorage("users:* !users:{sub}:delete !bad:{sub"))
+	assert.NoError(t, err)
+	_, b, err := mjwt.ExtractClaims[AccessTokenClaims](kStore, token)
+	assert.NoError(t, err)
+	ps := b.Claims.Perms
+	assert.True(t, ps.IsDenied("users:alice:delete"))
+	assert.True(t, ps.IsDenied("users:bob:delete"))
+	assert.True(t, ps.IsDenied("bad:x"))
+	assert.False(t, ps.IsDenied("users:alice:read"))
+	assert.False(t, ps.Grants("users:alice:delete"))
+	assert.True(t, ps.Grants("users:alice:read"))
+	assert.False(t, ps.Freeze().Grants("users:alice:delete"))
+	assert.False(t, ps.AllOf(ParsePermStorage("users:alice:delete")))
+	assert.True(t, ps.AllOf(ParsePermStorage("users:alice:read")))
+}
+
+func TestExtractAccessTokenWithTemplates(t *testing.T) {
+	t.Parallel()
+
+	kStore := mjwt.NewKeyStore()
+	s := newTestIssuer(t, "key1", kStore)
+
+	token, err := CreateAccessToken(s, "user1", "", nil, ParsePermStorage("users:{sub}:* posts:read"))
+	assert.NoError(t, err)
+	_, b, err := ExtractAccessTokenWithTemplates(kStore, token)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"posts:read", "users:user1:*"}, b.Claims.Perms.Dump())
+
+	c, err := LoadRoleCatalogue(strings.NewReader("self:\n  perms: [\"users:{sub}:write\", \"mct:{claims.mct}\"]\n"))
+	assert.NoError(t, err)
+	token, err = CreateAccessTokenWithRoles(s, time.Minute, "user2", "", nil, []string{"self"}, nil)
+	assert.NoError(t, err)
+	_, b, err = ExtractAccessTokenWithRoles(kStore, c, token)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"mct:access-token", "users:user2:write"}, b.Claims.Perms.Dump())
+}
@@ -3,14 +3,29 @@ package auth
 import (
 	"bufio"
 	"encoding/json"
+	"fmt"
 	"github.com/becheran/wildmatch-go"
 	"gopkg.in/yaml.v3"
 	"sort"
 	"strings"
 )
 
+// DenyPrefix marks a permission as denied, denied permissions take precedence
+// over any matching allowed permission
+const DenyPrefix = "!"
+
+// PermStorage is a set of allowed and denied permissions. It is not safe for
+// concurrent use, even Grants builds an index on first use, use Freeze to
+// create a FrozenPermStorage which can be shared between goroutines. Has,
+// IsDenied and Search never modify the PermStorage.
 type PermStorage struct {
 	values map[string]struct{}
+	denied map[string]struct{}
+
+	// index and denyIndex are built by Grants when first needed and cleared on
+	// changes
+	index     *permIndex
+	denyIndex *permIndex
 }
 
 func NewPermStorage() *PermStorage {
@@ -31,22 +46,89 @@ func (p *PermStorage) setup() *PermStorage {
 	if p.values == nil {
 		p.values = make(map[string]struct{})
 	}
+	if p.denied == nil {
+		p.denied = make(map[string]struct{})
+	}
 	return p
 }
 
+// Set adds an allowed permission or a denied permission if the value starts
+// with DenyPrefix
 func (p *PermStorage) Set(perm string) {
+	if d, ok := strings.CutPrefix(perm, DenyPrefix); ok {
+		p.denied[d] = struct{}{}
+		p.denyIndex = nil
+		return
+	}
 	p.values[perm] = struct{}{}
+	p.index = nil
 }
 
+// Clear removes an allowed permission or a denied permission if the value
+// starts with DenyPrefix
 func (p *PermStorage) Clear(perm string) {
+	if d, ok := strings.CutPrefix(perm, DenyPrefix); ok {
+		delete(p.denied, d)
+		p.denyIndex = nil
+		return
+	}
 	delete(p.values, perm)
+	p.index = nil
+}
+
+// Deny adds a denied permission
+func (p *PermStorage) Deny(perm string) { p.Set(DenyPrefix + perm) }
+
+// IsDenied outputs true if any denied permission matches the permission, deny
+// entries are matched as hierarchical patterns in the same way as Grants and
+// unresolved template placeholders match any value
+func (p *PermStorage) IsDenied(perm string) bool {
+	if len(p.denied) == 0 {
+		return false
+	}
+	if _, ok := p.denied[perm]; ok {
+		return true
+	}
+	if p.denyIndex != nil {
+		return p.denyIndex.match(perm)
+	}
+	for d := range p.denied {
+		if permCovers(denyTemplatePattern(d), perm) {
+			return true
+		}
+	}
+	return false
 }
 
+// Has outputs true if the exact permission is allowed and not denied
 func (p *PermStorage) Has(perm string) bool {
 	_, ok := p.values[perm]
-	return ok
+	return ok && !p.IsDenied(perm)
 }
 
+// Grants treats the stored permissions as hierarchical patterns separated by
+// ":" and outputs true if any of them match the permission. A "*" segment
+// matches a single level and a trailing "*" matches all levels below, so
+// "mjwt:admin:*" grants "mjwt:admin:users" and "mjwt:admin:users:delete".
+// Denied permissions always take precedence.
+func (p *PermStorage) Grants(perm string) bool {
+	if p.denyIndex == nil && len(p.denied) > 0 {
+		p.denyIndex = newDenyIndex(p.denied)
+	}
+	if p.IsDenied(perm) {
+		return false
+	}
+	if _, ok := p.values[perm]; ok {
+		return true
+	}
+	if p.index == nil {
+		p.index = newPermIndex(p.values)
+	}
+	return p.index.match(perm)
+}
+
+// OneOf outputs true if any allowed permission of o is allowed and not denied
+// in p
 func (p *PermStorage) OneOf(o *PermStorage) bool {
 	for i := range o.values {
 		if p.Has(i) {
@@ -56,33 +138,51 @@ func (p *PermStorage) OneOf(o *PermStorage) bool {
 	return false
 }
 
+// Dump outputs the allowed permissions and the denied permissions with
+// DenyPrefix in sorted order
 func (p *PermStorage) Dump() []string {
-	a := make([]string, 0, len(p.values))
+	a := make([]string, 0, len(p.values)+len(p.denied))
 	for i := range p.values {
 		a = append(a, i)
 	}
+	for i := range p.denied {
+		a = append(a, DenyPrefix+i)
+	}
 	sort.Strings(a)
 	return a
 }
 
+// Search outputs the allowed permissions matching the wildmatch pattern,
+// permissions which are denied are excluded
 func (p *PermStorage) Search(v string) []string {
 	m := wildmatch.NewWildMatch(v)
 	var a []string
 	for i := range p.values {
-		if m.IsMatch(i) {
+		if m.IsMatch(i) && !p.IsDenied(i) {
 			a = append(a, i)
 		}
 	}
 	return a
 }
 
+// Filter outputs the allowed permissions matching any of the wildmatch
+// patterns, all denied permissions are kept so exclusions still apply to the
+// filtered permissions. Patterns starting with DenyPrefix are added as denied
+// permissions and take precedence over the matched permissions.
 func (p *PermStorage) Filter(match []string) *PermStorage {
 	out := NewPermStorage()
 	for _, i := range match {
+		if d, ok := strings.CutPrefix(i, DenyPrefix); ok {
+			out.Deny(d)
+			continue
+		}
 		for _, j := range p.Search(i) {
 			out.Set(j)
 		}
 	}
+	for i := range p.denied {
+		out.denied[i] = struct{}{}
+	}
 	return out
 }
 
@@ -94,8 +194,22 @@ func (p *PermStorage) prepare(a []string) {
 
 func (p *PermStorage) MarshalJSON() ([]byte, error) { return json.Marshal(p.Dump()) }
 
+// UnmarshalJSON reads a JSON array of permissions or a JSON string containing
+// permissions encoded with EncodeCompact
 func (p *PermStorage) UnmarshalJSON(bytes []byte) error {
 	p.setup()
+	if len(bytes) > 0 && bytes[0] == '"' {
+		var s string
+		if err := json.Unmarshal(bytes, &s); err != nil {
+			return err
+		}
+		c, err := ParseCompactPermStorage(s)
+		if err != nil {
+			return err
+		}
+		p.prepare(c.Dump())
+		return nil
+	}
 	var a []string
 	err := json.Unmarshal(bytes, &a)
 	if err != nil {
@@ -105,10 +219,20 @@ func (p *PermStorage) UnmarshalJSON(bytes []byte) error {
 	return nil
 }
 
-func (p *PermStorage) MarshalYAML() (interface{}, error) { return yaml.Marshal(p.Dump()) }
+func (p *PermStorage) MarshalYAML() (interface{}, error) { return p.Dump(), nil }
 
+// UnmarshalYAML reads a YAML sequence of permissions or a single string of
+// space separated permissions
 func (p *PermStorage) UnmarshalYAML(value *yaml.Node) error {
 	p.setup()
+	if value.Kind == yaml.ScalarNode {
+		var s string
+		if err := value.Decode(&s); err != nil {
+			return err
+		}
+		p.prepareFields(s)
+		return nil
+	}
 	var a []string
 	err := value.Decode(&a)
 	if err != nil {
@@ -117,3 +241,49 @@ func (p *PermStorage) UnmarshalYAML(value *yaml.Node) error {
 	p.prepare(a)
 	return nil
 }
+
+// prepareFields sets the space separated permissions
+func (p *PermStorage) prepareFields(perms string) {
+	p.prepare(strings.Fields(perms))
+}
+
+// MarshalText outputs the permissions as a single space separated string, this
+// is the form accepted by ParsePermStorage
+func (p *PermStorage) MarshalText() ([]byte, error) {
+	return []byte(strings.Join(p.Dump(), " ")), nil
+}
+
+// UnmarshalText reads space separated permissions
+func (p *PermStorage) UnmarshalText(text []byte) error {
+	p.setup()
+	p.prepareFields(string(text))
+	return nil
+}
+
+// MarshalTOML outputs the permissions as a TOML inline array, this implements
+// the Marshaler interface of github.com/BurntSushi/toml and a JSON array of
+// strings is also a valid TOML array
+func (p *PermStorage) MarshalTOML() ([]byte, error) { return p.MarshalJSON() }
+
+// UnmarshalTOML reads a decoded TOML array of permissions or a string of space
+// separated permissions
+func (p *PermStorage) UnmarshalTOML(v interface{}) error {
+	p.setup()
+	switch v := v.(type) {
+	case string:
+		p.prepareFields(v)
+	case []string:
+		p.prepare(v)
+	case []interface{}:
+		for _, i := range v {
+			s, ok := i.(string)
+			if !ok {
+				return fmt.Errorf("invalid permission type %T", i)
+			}
+			p.Set(s)
+		}
+	default:
+		return fmt.Errorf("invalid permissions type %T", v)
+	}
+	return nil
+}
@@ -2,8 +2,13 @@ package auth
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/BurntSushi/toml"
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
 	"sort"
+	"sync"
 	"testing"
 )
 
@@ -42,6 +47,24 @@ func TestPermStorage_Has(t *testing.T) {
 	assert.True(t, ps.Has("mjwt:test"))
 }
 
+func TestPermStorage_ConcurrentReads(t *testing.T) {
+	t.Parallel()
+	ps := ParsePermStorage("mjwt:test mjwt:admin:* !mjwt:admin:users")
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.True(t, ps.Has("mjwt:test"))
+			assert.True(t, ps.IsDenied("mjwt:admin:users"))
+			assert.False(t, ps.IsDenied("mjwt:admin:keys"))
+			assert.Len(t, ps.Search("mjwt:*"), 2)
+		}()
+	}
+	wg.Wait()
+	assert.Nil(t, ps.denyIndex)
+}
+
 func TestPermStorage_OneOf(t *testing.T) {
 	t.Parallel()
 	o := NewPermStorage()
@@ -74,6 +97,11 @@ func TestPermStorage_Filter(t *testing.T) {
 	sort.Strings(a)
 	assert.Equal(t, []string{"mjwt:other", "mjwt:other2", "mjwt:test", "mjwt:test2"}, a)
 	assert.Equal(t, []string{"mjwt:another"}, ps.Filter([]string{"mjwt:another"}).Dump())
+
+	// deny patterns are added to the output and take precedence
+	out := ps.Filter([]string{"mjwt:test*", "!mjwt:test2"})
+	assert.Equal(t, []string{"!mjwt:test2", "mjwt:test", "mjwt:test2"}, out.Dump())
+	assert.False(t, out.Grants("mjwt:test2"))
 }
 
 func TestPermStorage_MarshalJSON(t *testing.T) {
@@ -93,5 +121,170 @@ func TestPermStorage_MarshalYAML(t *testing.T) {
 	ps.Set("mjwt:test2")
 	b, err := ps.MarshalYAML()
 	assert.NoError(t, err)
-	assert.Equal(t, 0, bytes.Compare([]byte("- mjwt:test\n- mjwt:test2\n"), b.([]byte)))
+	assert.Equal(t, []string{"mjwt:test", "mjwt:test2"}, b)
+
+	out, err := yaml.Marshal(ps)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, bytes.Compare([]byte("- mjwt:test\n- mjwt:test2\n"), out))
+}
+
+func TestPermStorage_YAMLRoundTrip(t *testing.T) {
+	t.Parallel()
+	type config struct {
+		Perms   *PermStorage `yaml:"perms"`
+		Compact *PermStorage `yaml:"compact"`
+	}
+	var c config
+	assert.NoError(t, yaml.Unmarshal([]byte("perms:\n  - files:*\n  - '!files:delete'\ncompact: users:read  users:write\n"), &c))
+	assert.Equal(t, []string{"!files:delete", "files:*"}, c.Perms.Dump())
+	assert.Equal(t, []string{"users:read", "users:write"}, c.Compact.Dump())
+
+	b, err := yaml.Marshal(c)
+	assert.NoError(t, err)
+	assert.Equal(t, "perms:\n    - '!files:delete'\n    - files:*\ncompact:\n    - users:read\n    - users:write\n", string(b))
+
+	var c2 config
+	assert.NoError(t, yaml.Unmarshal(b, &c2))
+	assert.Equal(t, c.Perms.Dump(), c2.Perms.Dump())
+	assert.Equal(t, c.Compact.Dump(), c2.Compact.Dump())
+}
+
+func TestPermStorage_Text(t *testing.T) {
+	t.Parallel()
+	ps := ParsePermStorage("mjwt:test2 mjwt:test !mjwt:other")
+	b, err := ps.MarshalText()
+	assert.NoError(t, err)
+	assert.Equal(t, "!mjwt:other mjwt:test mjwt:test2", string(b))
+
+	var out PermStorage
+	assert.NoError(t, out.UnmarshalText(b))
+	assert.Equal(t, ps.Dump(), out.Dump())
+}
+
+func TestPermStorage_TOML(t *testing.T) {
+	t.Parallel()
+	ps := ParsePermStorage("mjwt:test !mjwt:other")
+	b, err := ps.MarshalTOML()
+	assert.NoError(t, err)
+	assert.Equal(t, `["!mjwt:other","mjwt:test"]`, string(b))
+
+	for _, i := range []interface{}{
+		"mjwt:test !mjwt:other",
+		[]string{"mjwt:test", "!mjwt:other"},
+		[]interface{}{"mjwt:test", "!mjwt:other"},
+	} {
+		var out PermStorage
+		assert.NoError(t, out.UnmarshalTOML(i))
+		assert.Equal(t, ps.Dump(), out.Dump())
+	}
+	assert.Error(t, new(PermStorage).UnmarshalTOML(5))
+	assert.Error(t, new(PermStorage).UnmarshalTOML([]interface{}{5}))
+}
+
+func TestPermStorage_TOMLRoundTrip(t *testing.T) {
+	t.Parallel()
+	type config struct {
+		Name  string       `toml:"name"`
+		Perms *PermStorage `toml:"perms"`
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, toml.NewEncoder(&buf).Encode(config{Name: "app", Perms: ParsePermStorage("mjwt:test !mjwt:other")}))
+	assert.Equal(t, "name = \"app\"\nperms = [\"!mjwt:other\",\"mjwt:test\"]\n", buf.String())
+
+	for _, i := range []string{
+		buf.String(),
+		"perms = [\"mjwt:test\", \"!mjwt:other\"]\n",
+		"perms = \"mjwt:test !mjwt:other\"\n",
+	} {
+		var out config
+		_, err := toml.Decode(i, &out)
+		assert.NoError(t, err, i)
+		assert.Equal(t, []string{"!mjwt:other", "mjwt:test"}, out.Perms.Dump(), i)
+	}
+
+	var out config
+	_, err := toml.Decode("perms = [1, 2]\n", &out)
+	assert.Error(t, err)
+}
+
+func TestPermStorage_Grants(t *testing.T) {
+	t.Parallel()
+	ps := ParsePermStorage("mjwt:admin:* mjwt:*:read files:doc-?:edit mjwt:test")
+	assert.True(t, ps.Grants("mjwt:test"))
+	assert.True(t, ps.Grants("mjwt:admin:users"))
+	assert.True(t, ps.Grants("mjwt:admin:users:delete"))
+	assert.False(t, ps.Grants("mjwt:admin"))
+	assert.True(t, ps.Grants("mjwt:posts:read"))
+	assert.False(t, ps.Grants("mjwt:posts:write"))
+	assert.False(t, ps.Grants("mjwt:posts:read:all"))
+	assert.True(t, ps.Grants("files:doc-1:edit"))
+	assert.False(t, ps.Grants("files:doc-10:edit"))
+	assert.False(t, ps.Grants("mjwt:test2"))
+
+	// changes rebuild the index
+	ps.Set("mjwt:test2")
+	assert.True(t, ps.Grants("mjwt:test2"))
+	ps.Clear("mjwt:admin:*")
+	assert.False(t, ps.Grants("mjwt:admin:users"))
+
+	assert.True(t, ParsePermStorage("*").Grants("anything:at:all"))
+	assert.False(t, NewPermStorage().Grants("mjwt:test"))
+}
+
+func BenchmarkPermStorage_Grants(b *testing.B) {
+	ps := NewPermStorage()
+	for i := range 10000 {
+		ps.Set(fmt.Sprintf("service%d:resource%d:*", i%100, i))
+	}
+	ps.Grants("warm:index")
+	b.ResetTimer()
+	for range b.N {
+		ps.Grants("service42:resource9942:read")
+	}
+}
+
+func TestPermStorage_Deny(t *testing.T) {
+	t.Parallel()
+	ps := ParsePermStorage("files:* files:read files:delete !files:delete !admin:*")
+	assert.True(t, ps.Has("files:read"))
+	assert.False(t, ps.Has("files:delete"))
+	assert.True(t, ps.IsDenied("admin:users"))
+	assert.True(t, ps.Grants("files:write"))
+	assert.False(t, ps.Grants("files:delete"))
+
+	ps.Set("admin:users")
+	assert.False(t, ps.Has("admin:users"))
+	assert.False(t, ps.Grants("admin:users"))
+	assert.False(t, ps.OneOf(ParsePermStorage("files:delete admin:users")))
+	assert.True(t, ps.OneOf(ParsePermStorage("files:delete files:read")))
+
+	a := ps.Search("files:*")
+	sort.Strings(a)
+	assert.Equal(t, []string{"files:*", "files:read"}, a)
+	assert.Equal(t, []string{"!admin:*", "!files:delete", "files:*"}, ps.Filter([]string{"files:?"}).Dump())
+
+	ps.Clear("!files:delete")
+	assert.True(t, ps.Has("files:delete"))
+	assert.True(t, ps.Grants("files:delete"))
+
+	ps.Deny("files:read")
+	assert.False(t, ps.Has("files:read"))
+}
+
+func TestPermStorage_DenyRoundTrip(t *testing.T) {
+	t.Parallel()
+	ps := ParsePermStorage("files:* !files:delete")
+	b, err := json.Marshal(ps)
+	assert.NoError(t, err)
+	assert.Equal(t, `["!files:delete","files:*"]`, string(b))
+
+	var out PermStorage
+	assert.NoError(t, json.Unmarshal(b, &out))
+	assert.True(t, out.Grants("files:read"))
+	assert.False(t, out.Grants("files:delete"))
+
+	var y PermStorage
+	assert.NoError(t, yaml.Unmarshal([]byte("- files:*\n- '!files:delete'\n"), &y))
+	assert.Equal(t, ps.Dump(), y.Dump())
 }
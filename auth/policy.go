@@ -0,0 +1,396 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/1f349/mjwt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidPolicy = errors.New("invalid policy")
+
+// PolicyEffect is the outcome of a matching PolicyRule
+type PolicyEffect string
+
+const (
+	PolicyAllow PolicyEffect = "allow"
+	PolicyDeny  PolicyEffect = "deny"
+)
+
+// Policy contains the rules evaluated by Evaluate. A matching deny rule always
+// takes precedence, otherwise a matching allow rule allows the request and if
+// no rule matches the request is denied. A deny rule condition which reads a
+// missing attribute is treated as met so deny rules fail closed.
+//
+// Conditions read attributes using the following paths:
+//
+//	token.sub, token.iss, token.jti, token.aud, token.roles, token.actors
+//	token.perms      the allowed and denied permissions of the token
+//	attr.<key>       request attributes, nested maps use attr.<key>.<child>
+//	time.hour        hour of the day (0-23) in the policy time zone
+//	time.weekday     lowercase day name, for example "monday"
+//	time.unix        seconds since the unix epoch
+type Policy struct {
+	Rules []PolicyRule `yaml:"rules"`
+
+	// TimeZone is the IANA time zone name used for time attributes, the
+	// default is UTC
+	TimeZone string `yaml:"timezone,omitempty"`
+
+	// Now replaces time.Now when evaluating time attributes
+	Now func() time.Time `yaml:"-"`
+
+	loc *time.Location
+}
+
+// PolicyRule matches when the token grants all the permissions and all the
+// conditions are true
+type PolicyRule struct {
+	Name       string            `yaml:"name"`
+	Effect     PolicyEffect      `yaml:"effect"`
+	Perms      []string          `yaml:"perms,omitempty"`
+	Conditions []PolicyCondition `yaml:"when,omitempty"`
+}
+
+// PolicyCondition compares an attribute with a literal Value or with another
+// attribute referenced by Ref
+//
+// The supported operators are eq, ne, in, not_in, contains, gt, gte, lt, lte
+// and exists.
+type PolicyCondition struct {
+	Attr  string      `yaml:"attr"`
+	Op    string      `yaml:"op"`
+	Value interface{} `yaml:"value,omitempty"`
+	Ref   string      `yaml:"ref,omitempty"`
+}
+
+var policyOps = []string{"eq", "ne", "in", "not_in", "contains", "gt", "gte", "lt", "lte", "exists"}
+
+// PolicyTrace explains the outcome of a single rule
+type PolicyTrace struct {
+	Rule    string
+	Effect  PolicyEffect
+	Matched bool
+	Reason  string
+}
+
+// PolicyDecision contains the outcome of evaluating a Policy
+type PolicyDecision struct {
+	Allowed bool
+	// Rule is the name of the rule which decided the outcome, empty if no rule
+	// matched
+	Rule  string
+	Trace []PolicyTrace
+}
+
+// Explain outputs the trace as human-readable lines
+func (d PolicyDecision) Explain() string {
+	var sb strings.Builder
+	for _, i := range d.Trace {
+		status := "skip"
+		if i.Matched {
+			status = "match"
+		}
+		fmt.Fprintf(&sb, "%s %s %s: %s\n", status, i.Effect, i.Rule, i.Reason)
+	}
+	switch {
+	case d.Rule == "":
+		sb.WriteString("deny: no rule matched\n")
+	case d.Allowed:
+		fmt.Fprintf(&sb, "allow: %s\n", d.Rule)
+	default:
+		fmt.Fprintf(&sb, "deny: %s\n", d.Rule)
+	}
+	return sb.String()
+}
+
+// LoadPolicy reads a YAML policy and validates it
+//
+//	timezone: Europe/London
+//	rules:
+//	  - name: owner-can-edit
+//	    effect: allow
+//	    perms: [files:write]
+//	    when:
+//	      - {attr: attr.owner, op: eq, ref: token.sub}
+//	  - name: office-hours
+//	    effect: deny
+//	    when:
+//	      - {attr: time.hour, op: lt, value: 9}
+func LoadPolicy(r io.Reader) (*Policy, error) {
+	var p Policy
+	if err := yaml.NewDecoder(r).Decode(&p); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Validate checks the effects, operators and time zone of the policy
+func (p *Policy) Validate() error {
+	loc, err := p.location()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidPolicy, err)
+	}
+	p.loc = loc
+	for i, r := range p.Rules {
+		if r.Effect != PolicyAllow && r.Effect != PolicyDeny {
+			return fmt.Errorf("%w: rule %d: unknown effect %q", ErrInvalidPolicy, i, r.Effect)
+		}
+		for _, c := range r.Conditions {
+			if c.Attr == "" || !slices.Contains(policyOps, c.Op) {
+				return fmt.Errorf("%w: rule %d: invalid condition %q %q", ErrInvalidPolicy, i, c.Attr, c.Op)
+			}
+		}
+	}
+	return nil
+}
+
+// location outputs the time zone of the policy, the location loaded by
+// Validate is reused if the TimeZone has not changed
+func (p *Policy) location() (*time.Location, error) {
+	if p.TimeZone == "" {
+		return time.UTC, nil
+	}
+	if p.loc != nil && p.loc.String() == p.TimeZone {
+		return p.loc, nil
+	}
+	return time.LoadLocation(p.TimeZone)
+}
+
+// Evaluate decides if the verified access token claims and request attributes
+// are allowed by the policy. The request is denied if the TimeZone is invalid.
+func (p *Policy) Evaluate(b mjwt.BaseTypeClaims[AccessTokenClaims], attrs map[string]interface{}) PolicyDecision {
+	var d PolicyDecision
+	loc, err := p.location()
+	if err != nil {
+		d.Trace = append(d.Trace, PolicyTrace{Effect: PolicyDeny, Matched: true, Reason: "invalid time zone: " + err.Error()})
+		return d
+	}
+
+	env := policyEnv{claims: b, attrs: attrs, now: time.Now()}
+	if p.Now != nil {
+		env.now = p.Now()
+	}
+	env.now = env.now.In(loc)
+
+	for _, r := range p.Rules {
+		ok, reason := env.match(r)
+		d.Trace = append(d.Trace, PolicyTrace{Rule: r.Name, Effect: r.Effect, Matched: ok, Reason: reason})
+		if !ok {
+			continue
+		}
+		if r.Effect == PolicyDeny && (d.Allowed || d.Rule == "") {
+			d.Allowed = false
+			d.Rule = r.Name
+		}
+		if r.Effect == PolicyAllow && d.Rule == "" {
+			d.Allowed = true
+			d.Rule = r.Name
+		}
+	}
+	return d
+}
+
+type policyEnv struct {
+	claims mjwt.BaseTypeClaims[AccessTokenClaims]
+	attrs  map[string]interface{}
+	now    time.Time
+}
+
+func (e policyEnv) match(r PolicyRule) (bool, string) {
+	for _, i := range r.Perms {
+		if e.claims.Claims.Perms == nil || !e.claims.Claims.Perms.Grants(i) {
+			return false, "missing permission " + i
+		}
+	}
+	var missing []string
+	for _, c := range r.Conditions {
+		ok, absent, reason := e.condition(c)
+		if ok {
+			continue
+		}
+		// an unknown value cannot show a deny rule does not apply
+		if absent && r.Effect == PolicyDeny {
+			missing = append(missing, reason)
+			continue
+		}
+		return false, reason
+	}
+	if len(missing) > 0 {
+		return true, strings.Join(missing, ", ") + ", failing closed"
+	}
+	return true, "all conditions met"
+}
+
+// condition outputs true if the condition is met, absent is true if the
+// condition failed because an attribute does not exist
+func (e policyEnv) condition(c PolicyCondition) (ok, absent bool, reason string) {
+	a, found := e.lookup(c.Attr)
+	if c.Op == "exists" {
+		if !found {
+			return false, false, c.Attr + " does not exist"
+		}
+		return true, false, ""
+	}
+	if !found {
+		return false, true, c.Attr + " does not exist"
+	}
+
+	v := c.Value
+	desc := fmt.Sprint(c.Value)
+	if c.Ref != "" {
+		v, found = e.lookup(c.Ref)
+		if !found {
+			return false, true, c.Ref + " does not exist"
+		}
+		desc = c.Ref
+	}
+
+	switch c.Op {
+	case "eq":
+		ok = policyEqual(a, v)
+	case "ne":
+		ok = !policyEqual(a, v)
+	case "in":
+		ok = policyContains(v, a)
+	case "not_in":
+		ok = !policyContains(v, a)
+	case "contains":
+		ok = policyContains(a, v)
+	case "gt", "gte", "lt", "lte":
+		ok = policyCompare(c.Op, a, v)
+	}
+	if !ok {
+		return false, false, fmt.Sprintf("%s (%v) %s %s failed", c.Attr, a, c.Op, desc)
+	}
+	return true, false, ""
+}
+
+func (e policyEnv) lookup(path string) (interface{}, bool) {
+	ns, key, _ := strings.Cut(path, ".")
+	switch ns {
+	case "token":
+		b := e.claims
+		switch key {
+		case "sub":
+			return b.Subject, true
+		case "iss":
+			return b.Issuer, true
+		case "jti":
+			return b.ID, true
+		case "aud":
+			return []string(b.Audience), true
+		case "roles":
+			return b.Claims.Roles, true
+		case "actors":
+			return b.Claims.Actor.Chain(), true
+		case "perms":
+			if b.Claims.Perms == nil {
+				return []string{}, true
+			}
+			return b.Claims.Perms.Dump(), true
+		}
+	case "attr":
+		var v interface{} = e.attrs
+		for _, i := range strings.Split(key, ".") {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			v, ok = m[i]
+			if !ok {
+				return nil, false
+			}
+		}
+		return v, true
+	case "time":
+		switch key {
+		case "hour":
+			return e.now.Hour(), true
+		case "weekday":
+			return strings.ToLower(e.now.Weekday().String()), true
+		case "unix":
+			return e.now.Unix(), true
+		}
+	}
+	return nil, false
+}
+
+// policyNumber converts numeric values to float64
+func policyNumber(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	case time.Time:
+		return float64(v.Unix()), true
+	}
+	return 0, false
+}
+
+func policyEqual(a, b interface{}) bool {
+	sa, aStr := a.(string)
+	sb, bStr := b.(string)
+	if aStr && bStr {
+		return sa == sb
+	}
+	if x, ok := policyNumber(a); ok {
+		if y, ok := policyNumber(b); ok {
+			return x == y
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// policyContains outputs true if list is a list containing v
+func policyContains(list, v interface{}) bool {
+	rv := reflect.ValueOf(list)
+	if rv.Kind() != reflect.Slice {
+		return false
+	}
+	for i := range rv.Len() {
+		if policyEqual(rv.Index(i).Interface(), v) {
+			return true
+		}
+	}
+	return false
+}
+
+func policyCompare(op string, a, b interface{}) bool {
+	x, ok := policyNumber(a)
+	if !ok {
+		return false
+	}
+	y, ok := policyNumber(b)
+	if !ok {
+		return false
+	}
+	switch op {
+	case "gt":
+		return x > y
+	case "gte":
+		return x >= y
+	case "lt":
+		return x < y
+	}
+	return x <= y
+}
@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"github.com/1f349/mjwt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPolicy = `
+rules:
+  - name: owner-can-edit
+    effect: allow
+    perms: [files:write]
+    when:
+      - {attr: attr.resource.owner, op: eq, ref: token.sub}
+  - name: admins-can-edit
+    effect: allow
+    when:
+      - {attr: token.roles, op: contains, value: admin}
+  - name: same-tenant
+    effect: deny
+    when:
+      - {attr: attr.tenant, op: ne, value: acme}
+  - name: office-hours
+    effect: deny
+    when:
+      - {attr: time.hour, op: lt, value: 9}
+  - name: weekdays
+    effect: deny
+    when:
+      - {attr: time.weekday, op: in, value: [saturday, sunday]}
+`
+
+func TestPolicy_Evaluate(t *testing.T) {
+	t.Parallel()
+	p, err := LoadPolicy(strings.NewReader(testPolicy))
+	assert.NoError(t, err)
+
+	// a monday at 10:00
+	now := time.Date(2024, time.January, 1, 10, 0, 0, 0, time.UTC)
+	p.Now = func() time.Time { return now }
+
+	claims := func(sub string, roles []string, perms string) mjwt.BaseTypeClaims[AccessTokenClaims] {
+		return mjwt.BaseTypeClaims[AccessTokenClaims]{
+			RegisteredClaims: jwt.RegisteredClaims{Subject: sub},
+			Claims:           AccessTokenClaims{Perms: ParsePermStorage(perms), Roles: roles},
+		}
+	}
+	attrs := func(owner, tenant string) map[string]interface{} {
+		return map[string]interface{}{"resource": map[string]interface{}{"owner": owner}, "tenant": tenant}
+	}
+
+	d := p.Evaluate(claims("user1", nil, "files:*"), attrs("user1", "acme"))
+	assert.True(t, d.Allowed)
+	assert.Equal(t, "owner-can-edit", d.Rule)
+	assert.Len(t, d.Trace, 5)
+
+	d = p.Evaluate(claims("user1", nil, "files:*"), attrs("user2", "acme"))
+	assert.False(t, d.Allowed)
+	assert.Equal(t, "", d.Rule)
+	assert.Contains(t, d.Explain(), "skip allow owner-can-edit: attr.resource.owner (user2) eq token.sub failed")
+	assert.Contains(t, d.Explain(), "deny: no rule matched")
+
+	d = p.Evaluate(claims("user1", nil, "files:* !files:write"), attrs("user1", "acme"))
+	assert.False(t, d.Allowed)
+	assert.Equal(t, "skip allow owner-can-edit: missing permission files:write", strings.Split(d.Explain(), "\n")[0])
+
+	d = p.Evaluate(claims("user2", []string{"admin"}, ""), attrs("user1", "acme"))
+	assert.True(t, d.Allowed)
+	assert.Equal(t, "admins-can-edit", d.Rule)
+
+	// deny rules override allow rules
+	d = p.Evaluate(claims("user1", nil, "files:*"), attrs("user1", "other"))
+	assert.False(t, d.Allowed)
+	assert.Equal(t, "same-tenant", d.Rule)
+	assert.Contains(t, d.Explain(), "deny: same-tenant")
+
+	now = time.Date(2024, time.January, 1, 8, 0, 0, 0, time.UTC)
+	d = p.Evaluate(claims("user1", nil, "files:*"), attrs("user1", "acme"))
+	assert.False(t, d.Allowed)
+	assert.Equal(t, "office-hours", d.Rule)
+
+	now = time.Date(2024, time.January, 6, 12, 0, 0, 0, time.UTC)
+	d = p.Evaluate(claims("user1", nil, "files:*"), attrs("user1", "acme"))
+	assert.False(t, d.Allowed)
+	assert.Equal(t, "weekdays", d.Rule)
+
+	// missing attributes make deny rules fail closed
+	d = p.Evaluate(claims("user1", nil, "files:*"), map[string]interface{}{"resource": map[string]interface{}{"owner": "user1"}})
+	assert.False(t, d.Allowed)
+	assert.Equal(t, "same-tenant", d.Rule)
+	assert.Contains(t, d.Explain(), "match deny same-tenant: attr.tenant does not exist, failing closed")
+}
+
+func TestPolicy_TimeZone(t *testing.T) {
+	t.Parallel()
+	// 12:00 UTC is 07:00 in New York
+	now := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+	p := &Policy{
+		TimeZone: "America/New_York",
+		Now:      func() time.Time { return now },
+		Rules: []PolicyRule{
+			{Name: "office-hours", Effect: PolicyAllow, Conditions: []PolicyCondition{{Attr: "time.hour", Op: "gte", Value: 9}}},
+		},
+	}
+	// the time zone is used without calling Validate
+	d := p.Evaluate(mjwt.BaseTypeClaims[AccessTokenClaims]{}, nil)
+	assert.False(t, d.Allowed)
+	assert.Contains(t, d.Explain(), "time.hour (7) gte 9 failed")
+
+	p.TimeZone = "Invalid/Zone"
+	d = p.Evaluate(mjwt.BaseTypeClaims[AccessTokenClaims]{}, nil)
+	assert.False(t, d.Allowed)
+	assert.Contains(t, d.Explain(), "invalid time zone")
+}
+
+func TestPolicy_Conditions(t *testing.T) {
+	t.Parallel()
+	b := mjwt.BaseTypeClaims[AccessTokenClaims]{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "1", Audience: jwt.ClaimStrings{"api"}},
+		Claims:           AccessTokenClaims{Actor: &Actor{Subject: "service-a"}},
+	}
+	for _, i := range []struct {
+		cond PolicyCondition
+		ok   bool
+	}{
+		{PolicyCondition{Attr: "attr.size", Op: "gt", Value: 10}, true},
+		{PolicyCondition{Attr: "attr.size", Op: "lte", Value: 10.5}, false},
+		{PolicyCondition{Attr: "attr.size", Op: "eq", Value: 12}, true},
+		{PolicyCondition{Attr: "attr.size", Op: "gte", Ref: "attr.limit"}, false},
+		{PolicyCondition{Attr: "attr.name", Op: "eq", Value: "12"}, true},
+		{PolicyCondition{Attr: "attr.name", Op: "not_in", Value: []interface{}{"a", "b"}}, true},
+		{PolicyCondition{Attr: "attr.name", Op: "exists"}, true},
+		{PolicyCondition{Attr: "attr.missing", Op: "exists"}, false},
+		{PolicyCondition{Attr: "attr.missing", Op: "ne", Value: "x"}, false},
+		{PolicyCondition{Attr: "token.aud", Op: "contains", Value: "api"}, true},
+		{PolicyCondition{Attr: "token.actors", Op: "contains", Value: "service-a"}, true},
+		{PolicyCondition{Attr: "token.perms", Op: "eq", Value: []string{}}, true},
+		{PolicyCondition{Attr: "time.unix", Op: "gt", Value: 0}, true},
+	} {
+		p := &Policy{Rules: []PolicyRule{{Name: "rule", Effect: PolicyAllow, Conditions: []PolicyCondition{i.cond}}}}
+		d := p.Evaluate(b, map[string]interface{}{"size": 12, "limit": int64(20), "name": "12"})
+		assert.Equal(t, i.ok, d.Allowed, "%#v", i.cond)
+	}
+
+	// missing attributes only match in deny rules
+	p := &Policy{Rules: []PolicyRule{
+		{Name: "allow", Effect: PolicyAllow},
+		{Name: "deny", Effect: PolicyDeny, Conditions: []PolicyCondition{{Attr: "attr.missing", Op: "not_in", Value: []interface{}{"a"}}}},
+	}}
+	assert.False(t, p.Evaluate(b, nil).Allowed)
+	p.Rules[1].Conditions = []PolicyCondition{{Attr: "token.sub", Op: "eq", Ref: "attr.missing"}}
+	assert.False(t, p.Evaluate(b, nil).Allowed)
+	p.Rules[1].Conditions = []PolicyCondition{{Attr: "attr.missing", Op: "exists"}}
+	assert.True(t, p.Evaluate(b, nil).Allowed)
+}
+
+func TestLoadPolicy_Invalid(t *testing.T) {
+	t.Parallel()
+	_, err := LoadPolicy(strings.NewReader("rules:\n  - {name: a, effect: maybe}\n"))
+	assert.ErrorIs(t, err, ErrInvalidPolicy)
+	_, err = LoadPolicy(strings.NewReader("rules:\n  - {name: a, effect: allow, when: [{attr: token.sub, op: like}]}\n"))
+	assert.ErrorIs(t, err, ErrInvalidPolicy)
+	p, err := LoadPolicy(strings.NewReader(""))
+	assert.NoError(t, err)
+	assert.False(t, p.Evaluate(mjwt.BaseTypeClaims[AccessTokenClaims]{}, nil).Allowed)
+}
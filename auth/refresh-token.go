@@ -8,8 +8,11 @@ import (
 
 // RefreshTokenClaims contains the JWT claims for a refresh token
 // AccessTokenId (ati) must match the similar JWT ID (jti) claim
+// Perms (per) optionally records the permissions granted to the access token
+// so a refreshed access token cannot be granted more
 type RefreshTokenClaims struct {
-	AccessTokenId string `json:"ati"`
+	AccessTokenId string       `json:"ati"`
+	Perms         *PermStorage `json:"per,omitempty"`
 }
 
 func (r RefreshTokenClaims) Valid() error { return nil }
@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"github.com/1f349/mjwt"
+	"github.com/golang-jwt/jwt/v4"
+	"gopkg.in/yaml.v3"
+	"io"
+	"sort"
+	"time"
+)
+
+var ErrUnknownRole = errors.New("unknown role")
+var ErrRoleCycle = errors.New("role include cycle")
+
+// Role contains the permissions of a role and the names of other roles it
+// includes
+type Role struct {
+	Description string       `yaml:"description,omitempty"`
+	Includes    []string     `yaml:"includes,omitempty"`
+	Perms       *PermStorage `yaml:"perms,omitempty"`
+}
+
+// RoleCatalogue maps role names to role definitions
+type RoleCatalogue struct {
+	roles map[string]*Role
+}
+
+// NewRoleCatalogue creates an empty RoleCatalogue
+func NewRoleCatalogue() *RoleCatalogue {
+	return &RoleCatalogue{roles: make(map[string]*Role)}
+}
+
+// LoadRoleCatalogue reads a YAML mapping of role names to role definitions
+//
+//	viewer:
+//	  perms: [posts:read]
+//	editor:
+//	  includes: [viewer]
+//	  perms: [posts:write, "!posts:delete"]
+//
+// The catalogue is validated with Validate before it is returned.
+func LoadRoleCatalogue(r io.Reader) (*RoleCatalogue, error) {
+	c := NewRoleCatalogue()
+	if err := yaml.NewDecoder(r).Decode(&c.roles); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if c.roles == nil {
+		c.roles = make(map[string]*Role)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Add registers or replaces a role
+func (c *RoleCatalogue) Add(name string, role *Role) {
+	c.roles[name] = role
+}
+
+// Get outputs the role definition
+func (c *RoleCatalogue) Get(name string) (*Role, bool) {
+	r, ok := c.roles[name]
+	return r, ok
+}
+
+// Names outputs the sorted role names
+func (c *RoleCatalogue) Names() []string {
+	a := make([]string, 0, len(c.roles))
+	for i := range c.roles {
+		a = append(a, i)
+	}
+	sort.Strings(a)
+	return a
+}
+
+// Validate checks all included roles exist and there are no include cycles
+func (c *RoleCatalogue) Validate() error {
+	for _, name := range c.Names() {
+		if err := c.walk(name, make(map[string]bool), func(*Role) {}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Resolve expands the roles and their included roles into a PermStorage
+// containing all allowed and denied permissions
+func (c *RoleCatalogue) Resolve(roles ...string) (*PermStorage, error) {
+	out := NewPermStorage()
+	for _, name := range roles {
+		err := c.walk(name, make(map[string]bool), func(r *Role) {
+			if r.Perms == nil {
+				return
+			}
+			for _, i := range r.Perms.Dump() {
+				out.Set(i)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// walk calls fn for the role and each included role, stack contains the roles
+// currently being expanded and is used to detect cycles
+func (c *RoleCatalogue) walk(name string, stack map[string]bool, fn func(r *Role)) error {
+	if stack[name] {
+		return fmt.Errorf("%w: %s", ErrRoleCycle, name)
+	}
+	r, ok := c.roles[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownRole, name)
+	}
+	stack[name] = true
+	defer delete(stack, name)
+	fn(r)
+	for _, i := range r.Includes {
+		if err := c.walk(i, stack, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExpandRoles outputs the permissions of the claims combined with the expanded
+// permissions of the roles in the rol claim
+func (c *RoleCatalogue) ExpandRoles(claims AccessTokenClaims) (*PermStorage, error) {
+	out, err := c.Resolve(claims.Roles...)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Perms != nil {
+		for _, i := range claims.Perms.Dump() {
+			out.Set(i)
+		}
+	}
+	return out, nil
+}
+
+// CreateAccessTokenWithRoles creates an access token with the role names in
+// the rol claim, the roles are expanded when the token is verified with
+// ExtractAccessTokenWithRoles. The permissions are optional extra permissions.
+func CreateAccessTokenWithRoles(p *mjwt.Issuer, dur time.Duration, sub, id string, aud jwt.ClaimStrings, roles []string, perms *PermStorage) (string, error) {
+	if perms == nil {
+		perms = NewPermStorage()
+	}
+	return p.GenerateJwt(sub, id, aud, dur, AccessTokenClaims{Perms: perms, Roles: roles})
+}
+
+// ExtractAccessTokenWithRoles verifies an access token and replaces the
+// permissions with the permissions expanded from the rol claim using the
+// RoleCatalogue. Permission templates in the expanded permissions are resolved
+// using the subject and claims of the token.
+func ExtractAccessTokenWithRoles(ks *mjwt.KeyStore, c *RoleCatalogue, token string) (*jwt.Token, mjwt.BaseTypeClaims[AccessTokenClaims], error) {
+	tok, b, err := mjwt.ExtractClaims[AccessTokenClaims](ks, token)
+	if err != nil {
+		return nil, b, err
+	}
+	perms, err := c.ExpandRoles(b.Claims)
+	if err != nil {
+		return nil, b, mjwt.NewVerifyError(mjwt.ReasonInvalidClaims, err)
+	}
+	b.Claims.Perms = perms
+	if err := resolveClaimTemplates(tok, &b.Claims); err != nil {
+		return nil, b, err
+	}
+	return tok, b, nil
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"github.com/1f349/mjwt"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testRoles = `
+viewer:
+  description: Read only access
+  perms: [posts:read, comments:read]
+editor:
+  includes: [viewer]
+  perms: [posts:*, "!posts:delete"]
+admin:
+  includes: [editor, viewer]
+  perms: [users:*]
+`
+
+func TestLoadRoleCatalogue(t *testing.T) {
+	t.Parallel()
+	c, err := LoadRoleCatalogue(strings.NewReader(testRoles))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"admin", "editor", "viewer"}, c.Names())
+	r, ok := c.Get("viewer")
+	assert.True(t, ok)
+	assert.Equal(t, "Read only access", r.Description)
+
+	ps, err := c.Resolve("admin")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"!posts:delete", "comments:read", "posts:*", "posts:read", "users:*"}, ps.Dump())
+	assert.True(t, ps.Grants("posts:write"))
+	assert.False(t, ps.Grants("posts:delete"))
+
+	_, err = c.Resolve("viewer", "missing")
+	assert.ErrorIs(t, err, ErrUnknownRole)
+
+	_, err = LoadRoleCatalogue(strings.NewReader("a:\n  includes: [b]\nb:\n  includes: [a]\n"))
+	assert.ErrorIs(t, err, ErrRoleCycle)
+	_, err = LoadRoleCatalogue(strings.NewReader("a:\n  includes: [b]\n"))
+	assert.ErrorIs(t, err, ErrUnknownRole)
+
+	c, err = LoadRoleCatalogue(strings.NewReader(""))
+	assert.NoError(t, err)
+	assert.Empty(t, c.Names())
+}
+
+func TestExtractAccessTokenWithRoles(t *testing.T) {
+	t.Parallel()
+
+	kStore := mjwt.NewKeyStore()
+	s := newTestIssuer(t, "key1", kStore)
+	c, err := LoadRoleCatalogue(strings.NewReader(testRoles))
+	assert.NoError(t, err)
+
+	token, err := CreateAccessTokenWithRoles(s, time.Minute, "1", "", nil, []string{"editor"}, ParsePermStorage("extra:perm"))
+	assert.NoError(t, err)
+
+	_, b, err := mjwt.ExtractClaims[AccessTokenClaims](kStore, token)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"editor"}, b.Claims.Roles)
+	assert.Equal(t, []string{"extra:perm"}, b.Claims.Perms.Dump())
+
+	_, b, err = ExtractAccessTokenWithRoles(kStore, c, token)
+	assert.NoError(t, err)
+	assert.True(t, b.Claims.Perms.Has("extra:perm"))
+	assert.True(t, b.Claims.Perms.Has("comments:read"))
+	assert.True(t, b.Claims.Perms.Grants("posts:edit"))
+	assert.False(t, b.Claims.Perms.Grants("posts:delete"))
+
+	token, err = CreateAccessTokenWithRoles(s, time.Minute, "1", "", nil, []string{"missing"}, nil)
+	assert.NoError(t, err)
+	_, _, err = ExtractAccessTokenWithRoles(kStore, c, token)
+	assert.ErrorIs(t, err, ErrUnknownRole)
+	assert.ErrorIs(t, err, mjwt.ErrVerifyInvalidClaims)
+}
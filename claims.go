@@ -11,19 +11,32 @@ var ErrClaimTypeMismatch = errors.New("claim type mismatch")
 
 // wrapClaims creates a BaseTypeClaims wrapper for a generic claims struct
 func wrapClaims[T Claims](sub, id, issuer string, aud jwt.ClaimStrings, dur time.Duration, claims T) *BaseTypeClaims[T] {
+	return wrapClaimsWithOptions[T](issuer, &tokenOptions{sub: sub, id: id, aud: aud, dur: dur}, claims)
+}
+
+// wrapClaimsWithOptions creates a BaseTypeClaims wrapper for a generic claims
+// struct using the registered claim values from tokenOptions
+func wrapClaimsWithOptions[T Claims](issuer string, o *tokenOptions, claims T) *BaseTypeClaims[T] {
 	now := time.Now()
-	return (&BaseTypeClaims[T]{
+	b := &BaseTypeClaims[T]{
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    issuer,
-			Subject:   sub,
-			Audience:  aud,
-			ExpiresAt: jwt.NewNumericDate(now.Add(dur)),
-			NotBefore: jwt.NewNumericDate(now),
+			Subject:   o.sub,
+			Audience:  o.aud,
+			ExpiresAt: jwt.NewNumericDate(now.Add(o.dur)),
+			NotBefore: jwt.NewNumericDate(now.Add(o.nbf)),
 			IssuedAt:  jwt.NewNumericDate(now),
-			ID:        id,
+			ID:        o.id,
 		},
 		Claims: claims,
-	}).init()
+	}
+	if o.noIat {
+		b.IssuedAt = nil
+	}
+	if o.noExp {
+		b.ExpiresAt = nil
+	}
+	return b.init()
 }
 
 // ExtractClaims uses a Verifier to validate the MJWT token and returns the parsed
@@ -15,7 +15,8 @@ import (
 )
 
 type accessCmd struct {
-	issuer, subject, id, audience, duration, kID string
+	issuer, subject, id, audience, duration, kID, catalogue string
+	compact                                                 bool
 }
 
 func (s *accessCmd) Name() string { return "access" }
@@ -23,7 +24,7 @@ func (s *accessCmd) Synopsis() string {
 	return "Generates an access token with permissions using the private key"
 }
 func (s *accessCmd) Usage() string {
-	return `sign [-iss <issuer>] [-sub <subject>] [-id <id>] [-aud <audience>] [-dur <duration>] [-kid <name>] <private key path> <space separated permissions>
+	return `sign [-iss <issuer>] [-sub <subject>] [-id <id>] [-aud <audience>] [-dur <duration>] [-kid <name>] [-compact] [-catalogue <path>] <private key path> <space separated permissions>
   Output a signed MJWT token with the specified permissions.
 `
 }
@@ -35,6 +36,8 @@ func (s *accessCmd) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&s.audience, "aud", "", "Comma separated audience items for the MJWT")
 	f.StringVar(&s.duration, "dur", "15m", "Duration for the MJWT (default: 15m)")
 	f.StringVar(&s.kID, "kid", "", "The Key ID of the signing key")
+	f.StringVar(&s.catalogue, "catalogue", "", "Path to a YAML permission catalogue used to reject unknown permissions")
+	f.BoolVar(&s.compact, "compact", false, "Encode the permissions in the compact prefix tree form")
 }
 
 func (s *accessCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
@@ -55,6 +58,18 @@ func (s *accessCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}
 		ps.Set(args[i])
 	}
 
+	if s.catalogue != "" {
+		c, err := readPermCatalogue(s.catalogue)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, "Error: Failed to read permission catalogue: ", err)
+			return subcommands.ExitFailure
+		}
+		if err := c.Validate(ps); err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, "Error: ", err)
+			return subcommands.ExitFailure
+		}
+	}
+
 	var aud jwt.ClaimStrings
 	if s.audience != "" {
 		aud = strings.Split(s.audience, ",")
@@ -75,7 +90,7 @@ func (s *accessCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}
 		panic("this should not fail")
 	}
 
-	token, err = issuer.GenerateJwt(s.subject, s.id, aud, dur, auth.AccessTokenClaims{Perms: ps})
+	token, err = issuer.GenerateJwt(s.subject, s.id, aud, dur, auth.AccessTokenClaims{Perms: ps, CompactPerms: s.compact})
 	if err != nil {
 		_, _ = fmt.Fprintln(os.Stderr, "Error: Failed to generate MJWT token: ", err)
 		return subcommands.ExitFailure
@@ -84,3 +99,12 @@ func (s *accessCmd) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}
 	fmt.Println(token)
 	return subcommands.ExitSuccess
 }
+
+func readPermCatalogue(path string) (*auth.PermCatalogue, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return auth.LoadPermCatalogue(f)
+}
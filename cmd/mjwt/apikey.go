@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/1f349/mjwt"
+	"github.com/1f349/mjwt/auth"
+	"github.com/1f349/rsa-helper/rsaprivate"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/subcommands"
+	"os"
+	"strings"
+	"time"
+)
+
+type apiKeyCmd struct {
+	issuer, subject, audience, duration, kID, name, owner, createdBy, metadata string
+}
+
+func (s *apiKeyCmd) Name() string { return "apikey" }
+func (s *apiKeyCmd) Synopsis() string {
+	return "Generates an API key token with permissions using the private key"
+}
+func (s *apiKeyCmd) Usage() string {
+	return `apikey -name <name> [-iss <issuer>] [-sub <subject>] [-aud <audience>] [-dur <duration>] [-kid <name>] [-owner <owner>] [-by <creator>] [-meta <key=value,...>] <private key path> <space separated permissions>
+  Output a signed API key token with the specified permissions.
+  The key ID is written to stderr so the key can be added to a registry.
+  Keys without an expiry require a registry and cannot be created here.
+`
+}
+
+func (s *apiKeyCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&s.issuer, "iss", "MJWT Utility", "The name of the MJWT issuer (default: MJWT Utility)")
+	f.StringVar(&s.subject, "sub", "", "MJWT Subject")
+	f.StringVar(&s.audience, "aud", "", "Comma separated audience items for the MJWT")
+	f.StringVar(&s.duration, "dur", "720h", "Duration for the API key (default: 720h)")
+	f.StringVar(&s.kID, "kid", "", "The Key ID of the signing key")
+	f.StringVar(&s.name, "name", "", "Name of the API key")
+	f.StringVar(&s.owner, "owner", "", "Owner of the API key")
+	f.StringVar(&s.createdBy, "by", "", "Subject which created the API key")
+	f.StringVar(&s.metadata, "meta", "", "Comma separated key=value metadata for the API key")
+}
+
+func (s *apiKeyCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() < 1 {
+		_, _ = fmt.Fprintln(os.Stderr, "Error: Missing private key path argument")
+		return subcommands.ExitFailure
+	}
+
+	args := f.Args()
+	key, err := rsaprivate.Read(args[0])
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "Error: Failed to parse private key: ", err)
+		return subcommands.ExitFailure
+	}
+
+	ps := auth.NewPermStorage()
+	for i := 1; i < len(args); i++ {
+		ps.Set(args[i])
+	}
+
+	claims := auth.APIKeyClaims{Name: s.name, Owner: s.owner, CreatedBy: s.createdBy, Perms: ps}
+	if s.metadata != "" {
+		claims.Metadata = make(map[string]string)
+		for _, kv := range strings.Split(s.metadata, ",") {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok {
+				_, _ = fmt.Fprintln(os.Stderr, "Error: Invalid metadata item: ", kv)
+				return subcommands.ExitFailure
+			}
+			claims.Metadata[k] = v
+		}
+	}
+
+	var aud jwt.ClaimStrings
+	if s.audience != "" {
+		aud = strings.Split(s.audience, ",")
+	}
+	dur, err := time.ParseDuration(s.duration)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "Error: Failed to parse duration: ", err)
+		return subcommands.ExitFailure
+	}
+	if dur <= 0 {
+		_, _ = fmt.Fprintln(os.Stderr, "Error: Duration must be positive, keys without an expiry require a registry")
+		return subcommands.ExitFailure
+	}
+
+	kStore := mjwt.NewKeyStore()
+	kStore.LoadPrivateKey(s.kID, key)
+
+	issuer, err := mjwt.NewIssuerWithKeyStore(s.issuer, s.kID, jwt.SigningMethodRS512, kStore)
+	if err != nil {
+		panic("this should not fail")
+	}
+
+	token, apiKey, err := auth.CreateAPIKey(ctx, issuer, nil, dur, s.subject, aud, claims)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "Error: Failed to generate API key: ", err)
+		return subcommands.ExitFailure
+	}
+
+	_, _ = fmt.Fprintln(os.Stderr, "Key ID: ", apiKey.ID)
+	fmt.Println(token)
+	return subcommands.ExitSuccess
+}
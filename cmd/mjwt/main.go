@@ -13,6 +13,7 @@ func main() {
 	subcommands.Register(subcommands.CommandsCommand(), "")
 	subcommands.Register(&genCmd{}, "")
 	subcommands.Register(&accessCmd{}, "")
+	subcommands.Register(&apiKeyCmd{}, "")
 
 	flag.Parse()
 	ctx := context.Background()
@@ -6,6 +6,7 @@ toolchain go1.22.3
 
 require (
 	github.com/1f349/rsa-helper v0.0.2
+	github.com/BurntSushi/toml v1.5.0
 	github.com/becheran/wildmatch-go v1.0.0
 	github.com/go-jose/go-jose/v4 v4.0.5
 	github.com/golang-jwt/jwt/v4 v4.5.2
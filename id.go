@@ -0,0 +1,109 @@
+package mjwt
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"time"
+)
+
+// IDGenerator produces unique values for the JWT ID (jti) claim
+type IDGenerator interface {
+	NewID() (string, error)
+}
+
+// IDGeneratorFunc is an adapter to allow a function to be used as an IDGenerator
+type IDGeneratorFunc func() (string, error)
+
+func (f IDGeneratorFunc) NewID() (string, error) { return f() }
+
+var (
+	// RandomIDGenerator generates a random 128-bit hex encoded ID
+	RandomIDGenerator IDGenerator = IDGeneratorFunc(randomID)
+
+	// UUIDv4Generator generates a random version 4 UUID
+	UUIDv4Generator IDGenerator = IDGeneratorFunc(newUUIDv4)
+
+	// UUIDv7Generator generates a time ordered version 7 UUID
+	UUIDv7Generator IDGenerator = IDGeneratorFunc(newUUIDv7)
+
+	// ULIDGenerator generates a time ordered ULID
+	ULIDGenerator IDGenerator = IDGeneratorFunc(newULID)
+)
+
+// randomID generates a random 128-bit hex encoded ID
+func randomID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+func newUUIDv4() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return formatUUID(b, 4), nil
+}
+
+func newUUIDv7() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[6:]); err != nil {
+		return "", err
+	}
+	putUnixMilli48(b[:6], time.Now())
+	return formatUUID(b, 7), nil
+}
+
+// formatUUID sets the version and variant bits and outputs the UUID in the
+// standard hyphenated form
+func formatUUID(b [16]byte, version byte) string {
+	b[6] = (b[6] & 0x0f) | version<<4
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	var buf [36]byte
+	hex.Encode(buf[0:8], b[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], b[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], b[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], b[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], b[10:])
+	return string(buf[:])
+}
+
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+func newULID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[6:]); err != nil {
+		return "", err
+	}
+	putUnixMilli48(b[:6], time.Now())
+
+	// encode 128 bits as 26 base32 characters, the first character only holds
+	// the top 3 bits
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockfordAlphabet[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:]), nil
+}
+
+// putUnixMilli48 writes the lower 48 bits of the unix millisecond timestamp as
+// big endian
+func putUnixMilli48(b []byte, t time.Time) {
+	ms := uint64(t.UnixMilli())
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(ms)
+		ms >>= 8
+	}
+}
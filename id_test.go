@@ -0,0 +1,44 @@
+package mjwt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestIDGenerators(t *testing.T) {
+	t.Parallel()
+	gens := map[string]struct {
+		gen     IDGenerator
+		pattern string
+	}{
+		"random": {RandomIDGenerator, "^[0-9a-f]{32}$"},
+		"uuidv4": {UUIDv4Generator, "^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$"},
+		"uuidv7": {UUIDv7Generator, "^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$"},
+		"ulid":   {ULIDGenerator, "^[0-7][0-9A-HJKMNP-TV-Z]{25}$"},
+	}
+	for name, i := range gens {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			seen := make(map[string]struct{})
+			for range 100 {
+				id, err := i.gen.NewID()
+				assert.NoError(t, err)
+				assert.Regexp(t, i.pattern, id)
+				seen[id] = struct{}{}
+			}
+			assert.Len(t, seen, 100)
+		})
+	}
+}
+
+func TestULIDTimestamp(t *testing.T) {
+	t.Parallel()
+	a, err := newULID()
+	assert.NoError(t, err)
+	time.Sleep(2 * time.Millisecond)
+	b, err := newULID()
+	assert.NoError(t, err)
+	// the first 10 characters contain the timestamp
+	assert.Less(t, a[:10], b[:10])
+}
@@ -0,0 +1,92 @@
+package mjwt
+
+import (
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v4"
+	"strings"
+)
+
+// registeredClaimNames contains the JSON names of the claims decoded into
+// internalBaseTypeClaims
+var registeredClaimNames = []string{"iss", "sub", "aud", "exp", "nbf", "iat", "jti", "mct"}
+
+// UntrustedHeader contains the common header fields of an unverified token
+type UntrustedHeader struct {
+	Alg string
+	Kid string
+	Typ string
+	Raw map[string]interface{}
+}
+
+// UntrustedToken contains the decoded header and claims of a token which has
+// NOT been verified. The contents must only be used for logging and debugging
+// and never to make authorization decisions.
+type UntrustedToken struct {
+	Header UntrustedHeader
+	jwt.RegisteredClaims
+	ClaimType string
+
+	// Claims contains the JSON encoded custom claims excluding the registered
+	// claims and the claim type
+	Claims json.RawMessage
+}
+
+// Inspect decodes the header and claims of the token WITHOUT verifying the
+// signature or validating the claims. The output is untrusted.
+func Inspect(token string) (*UntrustedToken, error) {
+	var base internalBaseTypeClaims
+	tok, parts, err := jwt.NewParser().ParseUnverified(token, &base)
+	if err != nil {
+		return nil, wrapVerifyError(err)
+	}
+
+	// decode the claims again to collect the custom claims
+	payload, err := jwt.DecodeSegment(parts[1])
+	if err != nil {
+		return nil, NewVerifyError(ReasonMalformed, err)
+	}
+	var custom map[string]json.RawMessage
+	if err = json.Unmarshal(payload, &custom); err != nil {
+		return nil, NewVerifyError(ReasonMalformed, err)
+	}
+	for _, i := range registeredClaimNames {
+		delete(custom, i)
+	}
+	raw, err := json.Marshal(custom)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UntrustedToken{
+		Header: UntrustedHeader{
+			Alg: headerString(tok.Header, "alg"),
+			Kid: headerString(tok.Header, "kid"),
+			Typ: headerString(tok.Header, "typ"),
+			Raw: tok.Header,
+		},
+		RegisteredClaims: base.RegisteredClaims,
+		ClaimType:        base.ClaimType,
+		Claims:           raw,
+	}, nil
+}
+
+// String outputs a short human-readable summary of the untrusted token
+func (u *UntrustedToken) String() string {
+	var sb strings.Builder
+	sb.WriteString("UNTRUSTED alg=")
+	sb.WriteString(u.Header.Alg)
+	sb.WriteString(" kid=")
+	sb.WriteString(u.Header.Kid)
+	sb.WriteString(" mct=")
+	sb.WriteString(u.ClaimType)
+	sb.WriteString(" sub=")
+	sb.WriteString(u.Subject)
+	sb.WriteString(" jti=")
+	sb.WriteString(u.ID)
+	return sb.String()
+}
+
+func headerString(header map[string]interface{}, key string) string {
+	s, _ := header[key].(string)
+	return s
+}
@@ -0,0 +1,50 @@
+package mjwt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestInspect(t *testing.T) {
+	t.Parallel()
+	kStore := NewKeyStore()
+	s := newTestIssuer(t, "key1", kStore)
+
+	t.Run("valid token", func(t *testing.T) {
+		t.Parallel()
+		token, err := s.GenerateJwt("1", "test", []string{"example.com"}, time.Minute, testClaims{TestValue: "hello"})
+		assert.NoError(t, err)
+
+		u, err := Inspect(token)
+		assert.NoError(t, err)
+		assert.Equal(t, "RS512", u.Header.Alg)
+		assert.Equal(t, "key1", u.Header.Kid)
+		assert.Equal(t, "JWT", u.Header.Typ)
+		assert.Equal(t, "1", u.Subject)
+		assert.Equal(t, "test", u.ID)
+		assert.Equal(t, "mjwt.test", u.Issuer)
+		assert.Equal(t, []string{"example.com"}, []string(u.Audience))
+		assert.Equal(t, "testClaims", u.ClaimType)
+		assert.JSONEq(t, `{"TestValue":"hello"}`, string(u.Claims))
+		assert.Equal(t, "UNTRUSTED alg=RS512 kid=key1 mct=testClaims sub=1 jti=test", u.String())
+	})
+
+	t.Run("unverified token", func(t *testing.T) {
+		t.Parallel()
+		token, err := s.GenerateJwt("1", "test", nil, -time.Minute, testClaims{TestValue: "invalid"})
+		assert.NoError(t, err)
+
+		// expired tokens with invalid claims and signatures can still be inspected
+		u, err := Inspect(token[:len(token)-4] + "AAAA")
+		assert.NoError(t, err)
+		assert.Equal(t, "1", u.Subject)
+		assert.JSONEq(t, `{"TestValue":"invalid"}`, string(u.Claims))
+	})
+
+	t.Run("malformed token", func(t *testing.T) {
+		t.Parallel()
+		_, err := Inspect("not-a-token")
+		assert.ErrorIs(t, err, ErrVerifyMalformed)
+	})
+}
@@ -0,0 +1,106 @@
+package introspect
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client calls an RFC 7662 token introspection endpoint. Active responses are
+// cached until the token expires, inactive responses are never cached.
+type Client struct {
+	endpoint     string
+	clientId     string
+	clientSecret string
+	httpClient   *http.Client
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]*Response
+}
+
+// NewClient creates a Client for the endpoint using the client credentials for
+// basic authentication, an empty client ID disables authentication
+func NewClient(endpoint, clientId, clientSecret string) *Client {
+	return NewClientWithHttpClient(endpoint, clientId, clientSecret, http.DefaultClient)
+}
+
+// NewClientWithHttpClient creates a Client using a custom http.Client
+func NewClientWithHttpClient(endpoint, clientId, clientSecret string, httpClient *http.Client) *Client {
+	return &Client{
+		endpoint:     endpoint,
+		clientId:     clientId,
+		clientSecret: clientSecret,
+		httpClient:   httpClient,
+		cache:        make(map[[sha256.Size]byte]*Response),
+	}
+}
+
+// Introspect outputs the introspection response for the token, this uses the
+// cached response if one is available
+func (c *Client) Introspect(ctx context.Context, token string) (*Response, error) {
+	key := sha256.Sum256([]byte(token))
+	if resp := c.cached(key); resp != nil {
+		return resp, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, strings.NewReader(url.Values{"token": {token}}.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if c.clientId != "" {
+		req.SetBasicAuth(url.QueryEscape(c.clientId), url.QueryEscape(c.clientSecret))
+	}
+
+	httpResp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("introspect: unexpected status code %d", httpResp.StatusCode)
+	}
+
+	var resp Response
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return nil, err
+	}
+	if resp.Active && resp.ExpiresAt != 0 {
+		c.store(key, &resp)
+	}
+	return &resp, nil
+}
+
+func (c *Client) cached(key [sha256.Size]byte) *Response {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	resp, ok := c.cache[key]
+	if !ok {
+		return nil
+	}
+	if !time.Now().Before(resp.Expiry()) {
+		delete(c.cache, key)
+		return nil
+	}
+	return resp
+}
+
+// store saves the response and removes expired responses
+func (c *Client) store(key [sha256.Size]byte, resp *Response) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for k, v := range c.cache {
+		if !now.Before(v.Expiry()) {
+			delete(c.cache, k)
+		}
+	}
+	c.cache[key] = resp
+}
@@ -0,0 +1,62 @@
+package introspect
+
+import (
+	"context"
+	"github.com/1f349/mjwt/auth"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient(t *testing.T) {
+	t.Parallel()
+	issuer := setupTestIssuer(t)
+
+	var calls atomic.Int32
+	h := NewHandler(issuer.KeyStore(), nil)
+	h.Authenticate = func(rw http.ResponseWriter, req *http.Request) bool {
+		calls.Add(1)
+		user, pass, ok := req.BasicAuth()
+		if !ok || user != "rs" || pass != "secret" {
+			http.Error(rw, "unauthorized", http.StatusUnauthorized)
+			return false
+		}
+		return true
+	}
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	accessToken, err := auth.CreateAccessTokenWithDuration(issuer, time.Minute, "1", "test", nil, auth.ParsePermStorage("mjwt:a"))
+	assert.NoError(t, err)
+
+	t.Run("caches active responses", func(t *testing.T) {
+		c := NewClient(srv.URL, "rs", "secret")
+		for range 3 {
+			resp, err := c.Introspect(context.Background(), accessToken)
+			assert.NoError(t, err)
+			assert.True(t, resp.Active)
+			assert.True(t, resp.Perms().Has("mjwt:a"))
+		}
+		assert.Equal(t, int32(1), calls.Swap(0))
+	})
+
+	t.Run("does not cache inactive responses", func(t *testing.T) {
+		c := NewClient(srv.URL, "rs", "secret")
+		for range 2 {
+			resp, err := c.Introspect(context.Background(), "invalid")
+			assert.NoError(t, err)
+			assert.False(t, resp.Active)
+		}
+		assert.Equal(t, int32(2), calls.Swap(0))
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		c := NewClient(srv.URL, "rs", "wrong")
+		_, err := c.Introspect(context.Background(), accessToken)
+		assert.Error(t, err)
+		calls.Store(0)
+	})
+}
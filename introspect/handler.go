@@ -0,0 +1,174 @@
+// Package introspect provides an RFC 7662 token introspection endpoint for
+// MJWT tokens and a matching caching client.
+package introspect
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/1f349/mjwt"
+	"github.com/1f349/mjwt/auth"
+	"github.com/golang-jwt/jwt/v4"
+	"net/http"
+	"time"
+)
+
+// Response is the RFC 7662 token introspection response. The Scope field
+// contains the permissions of access tokens as a space-delimited list. The
+// Confirmation field contains the cnf claim of bound access tokens as required
+// by RFC 9449 section 6.2, callers must check the binding before accepting the
+// token.
+type Response struct {
+	Active    bool     `json:"active"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientId  string   `json:"client_id,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Audience  []string `json:"aud,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	ID        string   `json:"jti,omitempty"`
+
+	Confirmation *auth.Confirmation `json:"cnf,omitempty"`
+}
+
+// Perms outputs the Scope field as a PermStorage
+func (r *Response) Perms() *auth.PermStorage { return auth.ParsePermStorage(r.Scope) }
+
+// Expiry outputs the time the token expires or the zero time if unknown
+func (r *Response) Expiry() time.Time {
+	if r.ExpiresAt == 0 {
+		return time.Time{}
+	}
+	return time.Unix(r.ExpiresAt, 0)
+}
+
+// Handler is an http.Handler implementing RFC 7662 token introspection for
+// access and refresh tokens verified with a KeyStore
+type Handler struct {
+	KeyStore *mjwt.KeyStore
+
+	// IsRevoked is an optional callback to check if a verified token has been
+	// revoked
+	IsRevoked func(ctx context.Context, jti string) (bool, error)
+
+	// Authenticate is an optional callback to authorize the caller, if false is
+	// returned the callback must have written a response
+	Authenticate func(rw http.ResponseWriter, req *http.Request) bool
+
+	// Roles is an optional RoleCatalogue used to expand the rol claim into the
+	// scope, without it only the per claim of the token is included
+	Roles *auth.RoleCatalogue
+}
+
+// NewHandler creates a Handler using the KeyStore and optional revocation check
+func NewHandler(ks *mjwt.KeyStore, isRevoked func(ctx context.Context, jti string) (bool, error)) *Handler {
+	return &Handler{KeyStore: ks, IsRevoked: isRevoked}
+}
+
+func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		rw.Header().Set("Allow", http.MethodPost)
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.Authenticate != nil && !h.Authenticate(rw, req) {
+		return
+	}
+	token := req.PostFormValue("token")
+	if token == "" {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusBadRequest)
+		_, _ = rw.Write([]byte(`{"error":"invalid_request","error_description":"missing token"}`))
+		return
+	}
+
+	resp, err := h.Introspect(req.Context(), token)
+	if err != nil {
+		// no other details are included for invalid tokens as recommended by
+		// RFC 7662 section 2.2
+		resp = &Response{Active: false}
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(rw).Encode(resp)
+}
+
+// Introspect verifies the token and outputs the introspection response. An
+// error is returned if the token is invalid or has been revoked.
+func (h *Handler) Introspect(ctx context.Context, token string) (*Response, error) {
+	var claims jwt.RegisteredClaims
+	var resp Response
+
+	// bound tokens are accepted and the cnf claim is returned so the caller
+	// can check the binding
+	tok, a, err := mjwt.ExtractClaims[auth.BoundAccessTokenClaims](h.KeyStore, token)
+	if err == nil {
+		claims = a.RegisteredClaims
+		resp.TokenType = "access_token"
+		perms, err := h.scopePerms(tok, a.Claims.AccessTokenClaims)
+		if err != nil {
+			return nil, err
+		}
+		resp.Scope = auth.FormatScope(perms)
+		resp.Confirmation = a.Claims.Confirmation
+	} else {
+		_, r, err2 := mjwt.ExtractClaims[auth.RefreshTokenClaims](h.KeyStore, token)
+		if err2 != nil {
+			return nil, err
+		}
+		claims = r.RegisteredClaims
+		resp.TokenType = "refresh_token"
+	}
+
+	if h.IsRevoked != nil && claims.ID != "" {
+		revoked, err := h.IsRevoked(ctx, claims.ID)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, mjwt.ErrVerifyRevoked
+		}
+	}
+
+	resp.Active = true
+	resp.Subject = claims.Subject
+	resp.Audience = claims.Audience
+	resp.Issuer = claims.Issuer
+	resp.ID = claims.ID
+	if len(claims.Audience) > 0 {
+		resp.ClientId = claims.Audience[0]
+	}
+	if claims.ExpiresAt != nil {
+		resp.ExpiresAt = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		resp.IssuedAt = claims.IssuedAt.Unix()
+	}
+	if claims.NotBefore != nil {
+		resp.NotBefore = claims.NotBefore.Unix()
+	}
+	return &resp, nil
+}
+
+// scopePerms outputs the permissions of the access token with the roles
+// expanded and the permission templates resolved
+func (h *Handler) scopePerms(tok *jwt.Token, claims auth.AccessTokenClaims) (*auth.PermStorage, error) {
+	perms := claims.Perms
+	if h.Roles != nil {
+		var err error
+		perms, err = h.Roles.ExpandRoles(claims)
+		if err != nil {
+			return nil, mjwt.NewVerifyError(mjwt.ReasonInvalidClaims, err)
+		}
+	}
+	if perms == nil {
+		return nil, nil
+	}
+	data, err := auth.PermTemplateDataFromToken(tok)
+	if err != nil {
+		return nil, mjwt.NewVerifyError(mjwt.ReasonMalformed, err)
+	}
+	return perms.ResolveTemplates(data), nil
+}
@@ -0,0 +1,146 @@
+package introspect
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"github.com/1f349/mjwt"
+	"github.com/1f349/mjwt/auth"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setupTestIssuer(t *testing.T) *mjwt.Issuer {
+	kStore := mjwt.NewKeyStore()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	kStore.LoadPrivateKey("key1", key)
+	issuer, err := mjwt.NewIssuerWithKeyStore("mjwt.test", "key1", jwt.SigningMethodRS256, kStore)
+	assert.NoError(t, err)
+	return issuer
+}
+
+func introspectForm(t *testing.T, h http.Handler, token string) (int, Response) {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(url.Values{"token": {token}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	var resp Response
+	_ = json.NewDecoder(rec.Body).Decode(&resp)
+	return rec.Code, resp
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+	issuer := setupTestIssuer(t)
+
+	pair, err := auth.GenerateTokenPair(issuer, time.Minute, time.Hour, "1", "", "", jwt.ClaimStrings{"client1"}, nil, auth.ParsePermStorage("mjwt:a mjwt:b"))
+	assert.NoError(t, err)
+
+	h := NewHandler(issuer.KeyStore(), func(_ context.Context, jti string) (bool, error) {
+		return jti == pair.RefreshTokenID, nil
+	})
+
+	t.Run("access token", func(t *testing.T) {
+		t.Parallel()
+		code, resp := introspectForm(t, h, pair.AccessToken)
+		assert.Equal(t, http.StatusOK, code)
+		assert.True(t, resp.Active)
+		assert.Equal(t, "mjwt:a mjwt:b", resp.Scope)
+		assert.True(t, resp.Perms().Has("mjwt:a"))
+		assert.Equal(t, "access_token", resp.TokenType)
+		assert.Equal(t, "client1", resp.ClientId)
+		assert.Equal(t, "1", resp.Subject)
+		assert.Equal(t, pair.AccessTokenID, resp.ID)
+		assert.Equal(t, "mjwt.test", resp.Issuer)
+		assert.NotZero(t, resp.ExpiresAt)
+	})
+
+	t.Run("revoked token", func(t *testing.T) {
+		t.Parallel()
+		code, resp := introspectForm(t, h, pair.RefreshToken)
+		assert.Equal(t, http.StatusOK, code)
+		assert.False(t, resp.Active)
+		assert.Empty(t, resp.Subject)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		t.Parallel()
+		code, resp := introspectForm(t, h, "invalid")
+		assert.Equal(t, http.StatusOK, code)
+		assert.False(t, resp.Active)
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		t.Parallel()
+		code, _ := introspectForm(t, h, "")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("wrong method", func(t *testing.T) {
+		t.Parallel()
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+}
+
+func TestHandler_Introspect(t *testing.T) {
+	t.Parallel()
+	issuer := setupTestIssuer(t)
+	h := NewHandler(issuer.KeyStore(), nil)
+
+	refreshToken, err := auth.CreateRefreshToken(issuer, "1", "test", "test2", nil)
+	assert.NoError(t, err)
+	resp, err := h.Introspect(context.Background(), refreshToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "refresh_token", resp.TokenType)
+	assert.Equal(t, "", resp.Scope)
+
+	// bound tokens are active and include the cnf claim
+	boundToken, err := auth.CreateDPoPBoundAccessToken(issuer, time.Minute, "1", "test", nil, auth.ParsePermStorage("mjwt:test"), "thumbprint")
+	assert.NoError(t, err)
+	resp, err = h.Introspect(context.Background(), boundToken)
+	assert.NoError(t, err)
+	assert.True(t, resp.Active)
+	assert.Equal(t, &auth.Confirmation{JwkThumbprint: "thumbprint"}, resp.Confirmation)
+
+	certToken, err := issuer.GenerateJwt("1", "test", nil, time.Minute, auth.AccessTokenClaims{
+		Perms:        auth.NewPermStorage(),
+		Confirmation: &auth.Confirmation{CertificateThumbprint: "x5t"},
+	})
+	assert.NoError(t, err)
+	resp, err = h.Introspect(context.Background(), certToken)
+	assert.NoError(t, err)
+	assert.True(t, resp.Active)
+	assert.Equal(t, "x5t", resp.Confirmation.CertificateThumbprint)
+
+	// templates are resolved and roles are expanded with a RoleCatalogue
+	roleToken, err := auth.CreateAccessTokenWithRoles(issuer, time.Minute, "user1", "test", nil, []string{"self"}, auth.ParsePermStorage("posts:read !users:{sub}:delete"))
+	assert.NoError(t, err)
+	resp, err = h.Introspect(context.Background(), roleToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "!users:user1:delete posts:read", resp.Scope)
+
+	roles := auth.NewRoleCatalogue()
+	roles.Add("self", &auth.Role{Perms: auth.ParsePermStorage("users:{sub}:*")})
+	h.Roles = roles
+	resp, err = h.Introspect(context.Background(), roleToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "!users:user1:delete posts:read users:user1:*", resp.Scope)
+
+	missing, err := auth.CreateAccessTokenWithRoles(issuer, time.Minute, "user1", "test", nil, []string{"missing"}, nil)
+	assert.NoError(t, err)
+	_, err = h.Introspect(context.Background(), missing)
+	assert.ErrorIs(t, err, auth.ErrUnknownRole)
+
+	_, err = h.Introspect(context.Background(), "invalid")
+	assert.ErrorIs(t, err, mjwt.ErrVerifyMalformed)
+}
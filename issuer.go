@@ -10,20 +10,41 @@ import (
 // Issuer provides the signing for a PrivateKey identified by the KID in the
 // provided KeyStore
 type Issuer struct {
-	issuer   string
-	kid      string
-	signing  jwt.SigningMethod
-	keystore *KeyStore
+	issuer     string
+	kid        string
+	signing    jwt.SigningMethod
+	keystore   *KeyStore
+	defaultAud jwt.ClaimStrings
+	idGen      IDGenerator
+}
+
+// IssuerOption configures optional settings of an Issuer
+type IssuerOption func(i *Issuer)
+
+// WithDefaultAudience sets the audience used for tokens generated without an
+// explicit audience
+func WithDefaultAudience(aud ...string) IssuerOption {
+	return func(i *Issuer) { i.defaultAud = aud }
+}
+
+// WithIDGenerator sets the IDGenerator used for tokens generated without an
+// explicit ID. The default is RandomIDGenerator, a nil IDGenerator disables
+// automatic ID generation.
+func WithIDGenerator(gen IDGenerator) IssuerOption {
+	return func(i *Issuer) { i.idGen = gen }
 }
 
 // NewIssuer creates an Issuer with an empty KeyStore
-func NewIssuer(name, kid string, signing jwt.SigningMethod) (*Issuer, error) {
-	return NewIssuerWithKeyStore(name, kid, signing, NewKeyStore())
+func NewIssuer(name, kid string, signing jwt.SigningMethod, opts ...IssuerOption) (*Issuer, error) {
+	return NewIssuerWithKeyStore(name, kid, signing, NewKeyStore(), opts...)
 }
 
 // NewIssuerWithKeyStore creates an Issuer with a provided KeyStore
-func NewIssuerWithKeyStore(name, kid string, signing jwt.SigningMethod, keystore *KeyStore) (*Issuer, error) {
-	i := &Issuer{name, kid, signing, keystore}
+func NewIssuerWithKeyStore(name, kid string, signing jwt.SigningMethod, keystore *KeyStore, opts ...IssuerOption) (*Issuer, error) {
+	i := &Issuer{issuer: name, kid: kid, signing: signing, keystore: keystore, idGen: RandomIDGenerator}
+	for _, opt := range opts {
+		opt(i)
+	}
 	if i.keystore.HasPrivateKey(kid) {
 		return i, nil
 	}
@@ -35,18 +56,59 @@ func NewIssuerWithKeyStore(name, kid string, signing jwt.SigningMethod, keystore
 	return i, i.keystore.SaveSingleKey(kid)
 }
 
-// GenerateJwt produces a signed JWT in string form
+// GenerateJwt produces a signed JWT in string form. If the id is empty then the
+// IDGenerator of the Issuer is used.
 func (i *Issuer) GenerateJwt(sub, id string, aud jwt.ClaimStrings, dur time.Duration, claims Claims) (string, error) {
-	return i.SignJwt(wrapClaims[Claims](sub, id, i.issuer, aud, dur, claims))
+	return i.Issue(claims, WithSubject(sub), WithID(id), WithAudience(aud...), WithDuration(dur))
+}
+
+// Issue produces a signed JWT in string form using the provided TokenOption
+// values. Tokens without a duration use DefaultDuration, tokens without an
+// audience use the default audience of the Issuer and tokens without an ID use
+// the IDGenerator of the Issuer.
+func (i *Issuer) Issue(claims Claims, opts ...TokenOption) (string, error) {
+	o := newTokenOptions(opts)
+	if o.aud == nil {
+		o.aud = i.defaultAud
+	}
+	if o.id == "" && (i.idGen != nil || o.randomID) {
+		id, err := i.NewID()
+		if err != nil {
+			return "", err
+		}
+		o.id = id
+	}
+	return i.signJwt(wrapClaimsWithOptions[Claims](i.issuer, o, claims), o.header)
+}
+
+// NewID generates an ID using the IDGenerator of the Issuer, this falls back to
+// RandomIDGenerator if automatic ID generation is disabled. This can be used to
+// know the ID of a token before generating it.
+func (i *Issuer) NewID() (string, error) {
+	if i.idGen == nil {
+		return RandomIDGenerator.NewID()
+	}
+	return i.idGen.NewID()
 }
 
 // SignJwt produces a signed JWT in string form from a raw jwt.Claims structure
 func (i *Issuer) SignJwt(wrapped jwt.Claims) (string, error) {
+	return i.signJwt(wrapped, nil)
+}
+
+func (i *Issuer) signJwt(wrapped jwt.Claims, header map[string]interface{}) (string, error) {
 	key, err := i.PrivateKey()
 	if err != nil {
 		return "", err
 	}
 	token := jwt.NewWithClaims(i.signing, wrapped)
+	for k, v := range header {
+		// the algorithm and key id are controlled by the Issuer
+		if k == "alg" || k == "kid" {
+			continue
+		}
+		token.Header[k] = v
+	}
 	token.Header["kid"] = i.kid
 	return token.SignedString(key)
 }
@@ -56,6 +118,16 @@ func (i *Issuer) PrivateKey() (*rsa.PrivateKey, error) {
 	return i.keystore.GetPrivateKey(i.kid)
 }
 
+// Name outputs the name of the Issuer used in the issuer (iss) claim
+func (i *Issuer) Name() string {
+	return i.issuer
+}
+
+// SigningMethod outputs the jwt.SigningMethod used by the Issuer
+func (i *Issuer) SigningMethod() jwt.SigningMethod {
+	return i.signing
+}
+
 // KeyStore outputs the underlying KeyStore used by the Issuer
 func (i *Issuer) KeyStore() *KeyStore {
 	return i.keystore
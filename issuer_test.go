@@ -8,8 +8,18 @@ import (
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
+func newTestIssuer(t *testing.T, kid string, kStore *KeyStore) *Issuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	kStore.LoadPrivateKey(kid, key)
+	issuer, err := NewIssuerWithKeyStore("mjwt.test", kid, jwt.SigningMethodRS512, kStore)
+	assert.NoError(t, err)
+	return issuer
+}
+
 func TestNewIssuer(t *testing.T) {
 	t.Parallel()
 	t.Run("generate missing key for issuer", func(t *testing.T) {
@@ -57,3 +67,113 @@ func TestNewIssuer(t *testing.T) {
 		assert.True(t, key.Equal(privKey))
 	})
 }
+
+func TestIssuer_Issue(t *testing.T) {
+	t.Parallel()
+	kStore := NewKeyStore()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	kStore.LoadPrivateKey("test", key)
+	issuer, err := NewIssuerWithKeyStore("Test", "test", jwt.SigningMethodRS512, kStore, WithDefaultAudience("example.com"))
+	assert.NoError(t, err)
+
+	t.Run("defaults", func(t *testing.T) {
+		t.Parallel()
+		token, err := issuer.Issue(testClaims{TestValue: "hello"}, WithSubject("1"))
+		assert.NoError(t, err)
+		_, b, err := ExtractClaims[testClaims](kStore, token)
+		assert.NoError(t, err)
+		assert.Equal(t, "1", b.Subject)
+		assert.Len(t, b.ID, 32)
+		assert.Equal(t, jwt.ClaimStrings{"example.com"}, b.Audience)
+		assert.NotNil(t, b.IssuedAt)
+		assert.WithinDuration(t, b.IssuedAt.Add(DefaultDuration), b.ExpiresAt.Time, time.Second)
+	})
+
+	t.Run("options", func(t *testing.T) {
+		t.Parallel()
+		token, err := issuer.Issue(testClaims{TestValue: "hello"},
+			WithSubject("1"),
+			WithID("test"),
+			WithAudience("other.example.com"),
+			WithDuration(time.Hour),
+			WithoutIssuedAt(),
+			WithHeader("typ", "test+jwt"),
+			WithHeader("kid", "ignored"),
+		)
+		assert.NoError(t, err)
+		a, b, err := ExtractClaims[testClaims](kStore, token)
+		assert.NoError(t, err)
+		assert.Equal(t, "test", b.ID)
+		assert.Equal(t, jwt.ClaimStrings{"other.example.com"}, b.Audience)
+		assert.Nil(t, b.IssuedAt)
+		assert.Equal(t, "test+jwt", a.Header["typ"])
+		assert.Equal(t, "test", a.Header["kid"])
+		assert.WithinDuration(t, time.Now().Add(time.Hour), b.ExpiresAt.Time, 2*time.Second)
+	})
+
+	t.Run("not before offset", func(t *testing.T) {
+		t.Parallel()
+		token, err := issuer.Issue(testClaims{TestValue: "hello"}, WithNotBeforeOffset(time.Minute))
+		assert.NoError(t, err)
+		_, _, err = ExtractClaims[testClaims](kStore, token)
+		assert.ErrorIs(t, err, ErrVerifyNotYetValid)
+	})
+
+	t.Run("without expiry", func(t *testing.T) {
+		t.Parallel()
+		token, err := issuer.Issue(testClaims{TestValue: "hello"}, WithoutExpiry())
+		assert.NoError(t, err)
+		_, b, err := ExtractClaims[testClaims](kStore, token)
+		assert.NoError(t, err)
+		assert.Nil(t, b.ExpiresAt)
+	})
+
+	t.Run("random id", func(t *testing.T) {
+		t.Parallel()
+		token1, err := issuer.Issue(testClaims{TestValue: "hello"}, WithRandomID())
+		assert.NoError(t, err)
+		token2, err := issuer.Issue(testClaims{TestValue: "hello"}, WithRandomID())
+		assert.NoError(t, err)
+		_, b1, err := ExtractClaims[testClaims](kStore, token1)
+		assert.NoError(t, err)
+		_, b2, err := ExtractClaims[testClaims](kStore, token2)
+		assert.NoError(t, err)
+		assert.Len(t, b1.ID, 32)
+		assert.NotEqual(t, b1.ID, b2.ID)
+	})
+}
+
+func TestIssuer_NewID(t *testing.T) {
+	t.Parallel()
+	kStore := NewKeyStore()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	kStore.LoadPrivateKey("test", key)
+
+	t.Run("custom generator", func(t *testing.T) {
+		t.Parallel()
+		issuer, err := NewIssuerWithKeyStore("Test", "test", jwt.SigningMethodRS512, kStore, WithIDGenerator(UUIDv7Generator))
+		assert.NoError(t, err)
+		token, err := issuer.GenerateJwt("1", "", nil, time.Minute, testClaims{TestValue: "hello"})
+		assert.NoError(t, err)
+		_, b, err := ExtractClaims[testClaims](kStore, token)
+		assert.NoError(t, err)
+		assert.Regexp(t, "^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$", b.ID)
+	})
+
+	t.Run("disabled generator", func(t *testing.T) {
+		t.Parallel()
+		issuer, err := NewIssuerWithKeyStore("Test", "test", jwt.SigningMethodRS512, kStore, WithIDGenerator(nil))
+		assert.NoError(t, err)
+		token, err := issuer.GenerateJwt("1", "", nil, time.Minute, testClaims{TestValue: "hello"})
+		assert.NoError(t, err)
+		_, b, err := ExtractClaims[testClaims](kStore, token)
+		assert.NoError(t, err)
+		assert.Equal(t, "", b.ID)
+
+		id, err := issuer.NewID()
+		assert.NoError(t, err)
+		assert.Len(t, id, 32)
+	})
+}
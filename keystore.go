@@ -205,7 +205,8 @@ func (k *KeyStore) internalHasPublicKey(kid string) bool {
 
 // VerifyJwt parses the provided token string and validates it against the KID
 // using the KeyStore. An error is returned if the token fails to parse or if
-// there is no matching KID in the KeyStore.
+// there is no matching KID in the KeyStore. All errors are returned as a
+// VerifyError.
 func (k *KeyStore) VerifyJwt(token string, claims baseTypeClaim) (*jwt.Token, error) {
 	withClaims, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
 		kid, ok := token.Header["kid"].(string)
@@ -215,9 +216,9 @@ func (k *KeyStore) VerifyJwt(token string, claims baseTypeClaim) (*jwt.Token, er
 		return k.GetPublicKey(kid)
 	})
 	if err != nil {
-		return nil, err
+		return nil, wrapVerifyError(err)
 	}
-	return withClaims, claims.Valid()
+	return withClaims, wrapVerifyError(claims.Valid())
 }
 
 // SaveSingleKey writes the rsa.PrivateKey/rsa.PublicKey for the requested KID to
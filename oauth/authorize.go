@@ -0,0 +1,130 @@
+package oauth
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// handleAuthorize implements the authorization code flow with PKCE. Errors
+// before the redirect URI is validated are written to the response, all later
+// errors are sent to the client using the redirect URI.
+func (s *Server) handleAuthorize(rw http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	client, err := s.conf.Clients.GetClient(req.Context(), q.Get("client_id"))
+	if err != nil {
+		writeError(rw, errInvalidRequest.with("unknown client_id"))
+		return
+	}
+	redirectUri := q.Get("redirect_uri")
+	if !client.HasRedirectURI(redirectUri) {
+		writeError(rw, errInvalidRequest.with("invalid redirect_uri"))
+		return
+	}
+	state := q.Get("state")
+
+	if q.Get("response_type") != "code" {
+		redirectError(rw, req, redirectUri, state, "unsupported_response_type", "")
+		return
+	}
+
+	challenge := q.Get("code_challenge")
+	challengeType := q.Get("code_challenge_method")
+	if challengeType == "" {
+		challengeType = PKCEMethodPlain
+	}
+	if challenge == "" && client.Public {
+		redirectError(rw, req, redirectUri, state, "invalid_request", "public clients must use PKCE")
+		return
+	}
+	if challenge != "" && challengeType != PKCEMethodS256 && challengeType != PKCEMethodPlain {
+		redirectError(rw, req, redirectUri, state, "invalid_request", "unsupported code_challenge_method")
+		return
+	}
+
+	sub, ok := s.conf.Authenticate(rw, req)
+	if !ok {
+		return
+	}
+
+	code, err := randomCode()
+	if err != nil {
+		redirectError(rw, req, redirectUri, state, "server_error", "")
+		return
+	}
+	now := time.Now()
+	s.storeCode(code, &codeGrant{
+		clientId:      client.ID,
+		redirectUri:   redirectUri,
+		sub:           sub,
+		scope:         parseScope(q.Get("scope")),
+		challenge:     challenge,
+		challengeType: challengeType,
+		nonce:         q.Get("nonce"),
+		authTime:      now,
+		expires:       now.Add(s.conf.CodeDuration),
+	})
+
+	v := url.Values{}
+	v.Set("code", code)
+	if state != "" {
+		v.Set("state", state)
+	}
+	http.Redirect(rw, req, appendQuery(redirectUri, v), http.StatusFound)
+}
+
+func redirectError(rw http.ResponseWriter, req *http.Request, redirectUri, state, code, description string) {
+	v := url.Values{}
+	v.Set("error", code)
+	if description != "" {
+		v.Set("error_description", description)
+	}
+	if state != "" {
+		v.Set("state", state)
+	}
+	http.Redirect(rw, req, appendQuery(redirectUri, v), http.StatusFound)
+}
+
+func appendQuery(uri string, v url.Values) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	q := u.Query()
+	for k, i := range v {
+		q[k] = i
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// storeCode saves the code grant and removes expired codes
+func (s *Server) storeCode(code string, grant *codeGrant) {
+	s.codeMu.Lock()
+	defer s.codeMu.Unlock()
+	now := time.Now()
+	for k, v := range s.codes {
+		if v.expires.Before(now) {
+			delete(s.codes, k)
+		}
+	}
+	s.codes[code] = grant
+}
+
+// takeCode removes and outputs the code grant if it was issued to the client
+// for the redirect URI, codes can only be used once. Codes presented with the
+// wrong client or redirect URI are left in place so they cannot be burned by
+// another client.
+func (s *Server) takeCode(code, clientId, redirectUri string) (*codeGrant, bool) {
+	s.codeMu.Lock()
+	defer s.codeMu.Unlock()
+	grant, ok := s.codes[code]
+	if !ok || grant.clientId != clientId || grant.redirectUri != redirectUri {
+		return nil, false
+	}
+	delete(s.codes, code)
+	if grant.expires.Before(time.Now()) {
+		return nil, false
+	}
+	return grant, true
+}
@@ -0,0 +1,49 @@
+package oauth
+
+import (
+	"github.com/1f349/mjwt"
+	"net/http"
+	"strings"
+)
+
+// discoveryDocument is the OpenID Connect discovery and RFC 8414 authorization
+// server metadata document
+type discoveryDocument struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	JwksUri                           string   `json:"jwks_uri"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IdTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+}
+
+func (s *Server) handleDiscovery(rw http.ResponseWriter, _ *http.Request) {
+	base := strings.TrimSuffix(s.conf.Issuer.Name(), "/")
+	writeJson(rw, http.StatusOK, discoveryDocument{
+		Issuer:                            s.conf.Issuer.Name(),
+		AuthorizationEndpoint:             base + "/authorize",
+		TokenEndpoint:                     base + "/token",
+		RevocationEndpoint:                base + "/revoke",
+		IntrospectionEndpoint:             base + "/introspect",
+		JwksUri:                           base + "/.well-known/jwks.json",
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code", "refresh_token", "client_credentials"},
+		SubjectTypesSupported:             []string{"public"},
+		IdTokenSigningAlgValuesSupported:  []string{s.conf.Issuer.SigningMethod().Alg()},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{PKCEMethodS256, PKCEMethodPlain},
+	})
+}
+
+func (s *Server) handleJwks(rw http.ResponseWriter, _ *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	if err := mjwt.WriteJwkSetJson(rw, []*mjwt.Issuer{s.conf.Issuer}); err != nil {
+		http.Error(rw, "failed to write key set", http.StatusInternalServerError)
+	}
+}
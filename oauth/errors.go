@@ -0,0 +1,70 @@
+package oauth
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// Error is an OAuth 2.0 error response as defined in RFC 6749 section 5.2
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+	status      int
+}
+
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return "oauth: " + e.Code
+	}
+	return "oauth: " + e.Code + ": " + e.Description
+}
+
+// Is matches any Error with the same Code
+func (e *Error) Is(target error) bool {
+	var t *Error
+	return errors.As(target, &t) && t.Code == e.Code
+}
+
+func newError(status int, code, description string) *Error {
+	return &Error{Code: code, Description: description, status: status}
+}
+
+// with outputs a copy of the Error with a different description
+func (e *Error) with(description string) *Error {
+	return newError(e.status, e.Code, description)
+}
+
+var (
+	errInvalidRequest       = newError(http.StatusBadRequest, "invalid_request", "")
+	errInvalidClient        = newError(http.StatusUnauthorized, "invalid_client", "client authentication failed")
+	errInvalidGrant         = newError(http.StatusBadRequest, "invalid_grant", "")
+	errUnauthorizedClient   = newError(http.StatusBadRequest, "unauthorized_client", "")
+	errUnsupportedGrantType = newError(http.StatusBadRequest, "unsupported_grant_type", "")
+	errServerError          = newError(http.StatusInternalServerError, "server_error", "")
+)
+
+// writeError outputs the error as a JSON OAuth error response, non-OAuth
+// errors are hidden behind a server_error response
+func writeError(rw http.ResponseWriter, err error) {
+	var oErr *Error
+	if !errors.As(err, &oErr) {
+		oErr = errServerError
+	}
+	if oErr.status == http.StatusUnauthorized {
+		rw.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+	writeJson(rw, oErr.status, oErr)
+}
+
+func writeJson(rw http.ResponseWriter, status int, v any) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.WriteHeader(status)
+	_ = json.NewEncoder(rw).Encode(v)
+}
+
+func constantTimeEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,20 @@
+package oauth
+
+import (
+	"github.com/1f349/mjwt/introspect"
+	"net/http"
+)
+
+// newIntrospector creates the introspect.Handler used by the introspection
+// endpoint, only authenticated clients may introspect tokens
+func (s *Server) newIntrospector() *introspect.Handler {
+	h := introspect.NewHandler(s.conf.Issuer.KeyStore(), s.conf.Revocations.IsRevoked)
+	h.Authenticate = func(rw http.ResponseWriter, req *http.Request) bool {
+		if _, err := s.authenticateClient(req); err != nil {
+			writeError(rw, err)
+			return false
+		}
+		return true
+	}
+	return h
+}
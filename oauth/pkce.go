@@ -0,0 +1,50 @@
+package oauth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+)
+
+const (
+	PKCEMethodPlain = "plain"
+	PKCEMethodS256  = "S256"
+)
+
+// PKCEChallenge calculates the S256 code challenge for a code verifier
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// validPKCEVerifier checks the length and characters of the code verifier as
+// defined in RFC 7636 section 4.1
+func validPKCEVerifier(verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	for _, c := range verifier {
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+		case c == '-', c == '.', c == '_', c == '~':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// verifyPKCE checks the code verifier matches the stored challenge
+func verifyPKCE(challenge, method, verifier string) bool {
+	if !validPKCEVerifier(verifier) {
+		return false
+	}
+	switch method {
+	case PKCEMethodS256:
+		verifier = PKCEChallenge(verifier)
+	case PKCEMethodPlain:
+	default:
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(challenge), []byte(verifier)) == 1
+}
@@ -0,0 +1,23 @@
+package oauth
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestPKCEChallenge(t *testing.T) {
+	t.Parallel()
+	// example from RFC 7636 Appendix B
+	assert.Equal(t, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM", PKCEChallenge(testVerifier))
+}
+
+func TestVerifyPKCE(t *testing.T) {
+	t.Parallel()
+	assert.True(t, verifyPKCE(PKCEChallenge(testVerifier), PKCEMethodS256, testVerifier))
+	assert.True(t, verifyPKCE(testVerifier, PKCEMethodPlain, testVerifier))
+	assert.False(t, verifyPKCE(testVerifier, PKCEMethodS256, testVerifier))
+	assert.False(t, verifyPKCE(testVerifier, "other", testVerifier))
+	assert.False(t, verifyPKCE("short", PKCEMethodPlain, "short"))
+	assert.False(t, verifyPKCE(strings.Repeat("!", 43), PKCEMethodPlain, strings.Repeat("!", 43)))
+}
@@ -0,0 +1,25 @@
+package oauth
+
+import (
+	"net/http"
+	"slices"
+	"time"
+)
+
+// handleRevoke implements RFC 7009 token revocation. Invalid tokens and tokens
+// issued to other clients are ignored as required by the RFC.
+func (s *Server) handleRevoke(rw http.ResponseWriter, req *http.Request) {
+	client, err := s.authenticateClient(req)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	tok, err := s.introspector.Introspect(req.Context(), req.PostFormValue("token"))
+	if err == nil && tok.ID != "" && tok.ExpiresAt != 0 && slices.Contains(tok.Audience, client.ID) {
+		if err := s.conf.Revocations.Revoke(req.Context(), tok.ID, time.Unix(tok.ExpiresAt, 0)); err != nil {
+			writeError(rw, err)
+			return
+		}
+	}
+	rw.WriteHeader(http.StatusOK)
+}
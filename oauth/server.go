@@ -0,0 +1,193 @@
+// Package oauth provides an embeddable OAuth 2.0 and OpenID Connect
+// authorization server built on mjwt.Issuer, mjwt.KeyStore and
+// auth.PermStorage.
+package oauth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/1f349/mjwt"
+	"github.com/1f349/mjwt/auth"
+	"github.com/1f349/mjwt/introspect"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrMissingConfig = errors.New("missing required config")
+
+// Config contains the settings for a Server
+type Config struct {
+	// Issuer signs all tokens, the name of the Issuer is used as the base URL
+	// of the endpoints in the discovery document
+	Issuer *mjwt.Issuer
+
+	Clients     ClientStore
+	Users       UserStore
+	Revocations RevocationStore
+
+	// UsedRefreshTokens records redeemed refresh token IDs so each refresh
+	// token is consumed exactly once, even by concurrent requests
+	UsedRefreshTokens mjwt.ReplayStore
+
+	// Authenticate resolves the subject of the logged-in user for the
+	// authorize endpoint. If ok is false the function must have written a
+	// response, for example a redirect to a login page.
+	Authenticate func(rw http.ResponseWriter, req *http.Request) (sub string, ok bool)
+
+	AccessTokenDuration  time.Duration
+	RefreshTokenDuration time.Duration
+	CodeDuration         time.Duration
+}
+
+// Server provides the token, authorize, revoke, introspection and discovery
+// endpoints
+type Server struct {
+	conf         Config
+	introspector *introspect.Handler
+
+	codeMu sync.Mutex
+	codes  map[string]*codeGrant
+}
+
+// codeGrant contains the details of an issued authorization code
+type codeGrant struct {
+	clientId      string
+	redirectUri   string
+	sub           string
+	scope         []string
+	challenge     string
+	challengeType string
+	nonce         string
+	authTime      time.Time
+	expires       time.Time
+}
+
+// NewServer creates a Server. Issuer, Clients, Users and Authenticate are
+// required and ErrMissingConfig is returned if any are nil. A
+// MemoryRevocationStore and MemoryReplayStore are used if no RevocationStore
+// or UsedRefreshTokens store is provided and zero durations are replaced with
+// the defaults of 15 minutes for access tokens, 7 days for refresh tokens and
+// 1 minute for authorization codes.
+func NewServer(conf Config) (*Server, error) {
+	switch {
+	case conf.Issuer == nil:
+		return nil, fmt.Errorf("%w: Issuer", ErrMissingConfig)
+	case conf.Clients == nil:
+		return nil, fmt.Errorf("%w: Clients", ErrMissingConfig)
+	case conf.Users == nil:
+		return nil, fmt.Errorf("%w: Users", ErrMissingConfig)
+	case conf.Authenticate == nil:
+		return nil, fmt.Errorf("%w: Authenticate", ErrMissingConfig)
+	}
+	if conf.Revocations == nil {
+		conf.Revocations = NewMemoryRevocationStore()
+	}
+	if conf.UsedRefreshTokens == nil {
+		conf.UsedRefreshTokens = mjwt.NewMemoryReplayStore(0)
+	}
+	if conf.AccessTokenDuration == 0 {
+		conf.AccessTokenDuration = time.Minute * 15
+	}
+	if conf.RefreshTokenDuration == 0 {
+		conf.RefreshTokenDuration = time.Hour * 24 * 7
+	}
+	if conf.CodeDuration == 0 {
+		conf.CodeDuration = time.Minute
+	}
+	s := &Server{conf: conf, codes: make(map[string]*codeGrant)}
+	s.introspector = s.newIntrospector()
+	return s, nil
+}
+
+// Handler outputs an http.Handler serving all endpoints
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /authorize", s.handleAuthorize)
+	mux.HandleFunc("POST /token", s.handleToken)
+	mux.HandleFunc("POST /revoke", s.handleRevoke)
+	mux.Handle("POST /introspect", s.introspector)
+	mux.HandleFunc("GET /.well-known/openid-configuration", s.handleDiscovery)
+	mux.HandleFunc("GET /.well-known/jwks.json", s.handleJwks)
+	return mux
+}
+
+// authenticateClient reads the client credentials from the basic auth header
+// or the request form and checks them against the ClientStore
+func (s *Server) authenticateClient(req *http.Request) (*Client, error) {
+	id, secret, ok := req.BasicAuth()
+	if ok {
+		// RFC 6749 section 2.3.1 requires form-urlencoding before base64
+		var err1, err2 error
+		id, err1 = url.QueryUnescape(id)
+		secret, err2 = url.QueryUnescape(secret)
+		if err1 != nil || err2 != nil {
+			return nil, errInvalidClient
+		}
+	} else {
+		id = req.PostFormValue("client_id")
+		secret = req.PostFormValue("client_secret")
+	}
+	if id == "" {
+		return nil, errInvalidClient
+	}
+	client, err := s.conf.Clients.GetClient(req.Context(), id)
+	if err != nil {
+		if errors.Is(err, ErrClientNotFound) {
+			return nil, errInvalidClient
+		}
+		return nil, err
+	}
+	if !client.Public && !constantTimeEqual(client.Secret, secret) {
+		return nil, errInvalidClient
+	}
+	return client, nil
+}
+
+// grantPerms outputs the permissions of the user limited by the client and the
+// requested scope
+func grantPerms(user *User, client *Client, scope []string) *auth.PermStorage {
+	perms := user.Perms
+	if perms == nil {
+		perms = auth.NewPermStorage()
+	}
+	if client.Perms != nil {
+		perms = perms.Intersect(client.Perms)
+	}
+	return narrowScope(perms, scope)
+}
+
+// narrowScope outputs the permissions granted by both perms and the scope, an
+// empty scope keeps all permissions
+func narrowScope(perms *auth.PermStorage, scope []string) *auth.PermStorage {
+	if len(scope) == 0 {
+		return perms
+	}
+	return perms.Intersect(auth.ParsePermStorage(strings.Join(scope, " ")))
+}
+
+func parseScope(scope string) []string {
+	return strings.Fields(scope)
+}
+
+func hasScope(scope []string, v string) bool {
+	for _, i := range scope {
+		if i == v {
+			return true
+		}
+	}
+	return false
+}
+
+// randomCode generates a random 256-bit base64url encoded authorization code
+func randomCode() (string, error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b[:]), nil
+}
@@ -0,0 +1,436 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"github.com/1f349/mjwt"
+	"github.com/1f349/mjwt/auth"
+	"github.com/1f349/mjwt/introspect"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// testEncodedSecret contains characters which must be form-urlencoded in the
+// basic authentication header
+const testEncodedSecret = "a/b+c=d e%"
+
+const testVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+
+func setupTestServer(t *testing.T) (*httptest.Server, *mjwt.Issuer) {
+	kStore := mjwt.NewKeyStore()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	kStore.LoadPrivateKey("key1", key)
+	issuer, err := mjwt.NewIssuerWithKeyStore("https://auth.example.com", "key1", jwt.SigningMethodRS256, kStore)
+	assert.NoError(t, err)
+
+	server, err := NewServer(Config{
+		Issuer: issuer,
+		Clients: NewMemoryClientStore(
+			&Client{ID: "app", RedirectURIs: []string{"https://app.example.com/callback"}, Public: true},
+			&Client{ID: "bot", Secret: "bot-secret", Perms: auth.ParsePermStorage("mjwt:bot mjwt:read")},
+			&Client{ID: "svc", Secret: testEncodedSecret},
+		),
+		Users: NewMemoryUserStore(&User{
+			Subject: "user1",
+			Perms:   auth.ParsePermStorage("mjwt:read mjwt:write mjwt:admin"),
+			Profile: auth.IDTokenClaims{Name: "User One"},
+		}),
+		Authenticate: func(rw http.ResponseWriter, req *http.Request) (string, bool) {
+			if req.Header.Get("X-Test-User") == "" {
+				http.Error(rw, "login required", http.StatusUnauthorized)
+				return "", false
+			}
+			return req.Header.Get("X-Test-User"), true
+		},
+	})
+	assert.NoError(t, err)
+	srv := httptest.NewServer(server.Handler())
+	t.Cleanup(srv.Close)
+	return srv, issuer
+}
+
+func noRedirectClient() *http.Client {
+	return &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+}
+
+func authorize(t *testing.T, srv *httptest.Server, v url.Values, user string) *url.URL {
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/authorize?"+v.Encode(), nil)
+	assert.NoError(t, err)
+	if user != "" {
+		req.Header.Set("X-Test-User", user)
+	}
+	resp, err := noRedirectClient().Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	assert.NoError(t, err)
+	return loc
+}
+
+func postForm(t *testing.T, srv *httptest.Server, path string, v url.Values, basicUser, basicPass string) (*http.Response, map[string]interface{}) {
+	req, err := http.NewRequest(http.MethodPost, srv.URL+path, strings.NewReader(v.Encode()))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if basicUser != "" {
+		req.SetBasicAuth(basicUser, basicPass)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	var body map[string]interface{}
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+	return resp, body
+}
+
+func TestServer_AuthorizationCode(t *testing.T) {
+	t.Parallel()
+	srv, issuer := setupTestServer(t)
+
+	loc := authorize(t, srv, url.Values{
+		"response_type":         {"code"},
+		"client_id":             {"app"},
+		"redirect_uri":          {"https://app.example.com/callback"},
+		"scope":                 {"openid mjwt:read mjwt:write"},
+		"state":                 {"xyz"},
+		"nonce":                 {"abc"},
+		"code_challenge":        {PKCEChallenge(testVerifier)},
+		"code_challenge_method": {"S256"},
+	}, "user1")
+	assert.Equal(t, "app.example.com", loc.Host)
+	assert.Equal(t, "xyz", loc.Query().Get("state"))
+	code := loc.Query().Get("code")
+	assert.NotEmpty(t, code)
+
+	tokenForm := url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {"app"},
+		"code":          {code},
+		"redirect_uri":  {"https://app.example.com/callback"},
+		"code_verifier": {testVerifier},
+	}
+	resp, body := postForm(t, srv, "/token", tokenForm, "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "Bearer", body["token_type"])
+	assert.Equal(t, "mjwt:read mjwt:write", body["scope"])
+	accessToken := body["access_token"].(string)
+	refreshToken := body["refresh_token"].(string)
+
+	_, a, err := mjwt.ExtractClaims[auth.AccessTokenClaims](issuer.KeyStore(), accessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "user1", a.Subject)
+	assert.True(t, a.Claims.Perms.Has("mjwt:write"))
+	assert.False(t, a.Claims.Perms.Has("mjwt:admin"))
+
+	_, id, err := auth.ExtractIDToken(issuer.KeyStore(), body["id_token"].(string), "abc", accessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "User One", id.Claims.Name)
+	assert.Equal(t, "app", id.Claims.AuthorizedParty)
+
+	t.Run("code reuse", func(t *testing.T) {
+		resp, body := postForm(t, srv, "/token", tokenForm, "", "")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "invalid_grant", body["error"])
+	})
+
+	t.Run("introspect", func(t *testing.T) {
+		_, body := postForm(t, srv, "/introspect", url.Values{"token": {accessToken}}, "bot", "bot-secret")
+		assert.Equal(t, true, body["active"])
+		assert.Equal(t, "mjwt:read mjwt:write", body["scope"])
+		assert.Equal(t, "user1", body["sub"])
+		assert.Equal(t, "app", body["client_id"])
+		assert.Equal(t, "access_token", body["token_type"])
+
+		resp, _ := postForm(t, srv, "/introspect", url.Values{"token": {accessToken}}, "bot", "wrong")
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("refresh and revoke", func(t *testing.T) {
+		resp, body := postForm(t, srv, "/token", url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {"app"},
+			"refresh_token": {refreshToken},
+			"scope":         {"mjwt:read"},
+		}, "", "")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "mjwt:read", body["scope"])
+		newAccessToken := body["access_token"].(string)
+
+		// refresh tokens are single use
+		resp, body = postForm(t, srv, "/token", url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {"app"},
+			"refresh_token": {refreshToken},
+		}, "", "")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "invalid_grant", body["error"])
+
+		resp, _ = postForm(t, srv, "/revoke", url.Values{"client_id": {"app"}, "token": {newAccessToken}}, "", "")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		_, body = postForm(t, srv, "/introspect", url.Values{"token": {newAccessToken}}, "bot", "bot-secret")
+		assert.Equal(t, false, body["active"])
+	})
+}
+
+func codeTokens(t *testing.T, srv *httptest.Server, scope string) map[string]interface{} {
+	loc := authorize(t, srv, url.Values{
+		"response_type":         {"code"},
+		"client_id":             {"app"},
+		"redirect_uri":          {"https://app.example.com/callback"},
+		"scope":                 {scope},
+		"code_challenge":        {PKCEChallenge(testVerifier)},
+		"code_challenge_method": {"S256"},
+	}, "user1")
+	resp, body := postForm(t, srv, "/token", url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {"app"},
+		"code":          {loc.Query().Get("code")},
+		"redirect_uri":  {"https://app.example.com/callback"},
+		"code_verifier": {testVerifier},
+	}, "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	return body
+}
+
+func refreshForm(refreshToken, scope string) url.Values {
+	v := url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {"app"},
+		"refresh_token": {refreshToken},
+	}
+	if scope != "" {
+		v.Set("scope", scope)
+	}
+	return v
+}
+
+func TestServer_RefreshScope(t *testing.T) {
+	t.Parallel()
+	srv, issuer := setupTestServer(t)
+
+	body := codeTokens(t, srv, "mjwt:read")
+	assert.Equal(t, "mjwt:read", body["scope"])
+
+	// no scope keeps the original grant
+	resp, body := postForm(t, srv, "/token", refreshForm(body["refresh_token"].(string), ""), "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "mjwt:read", body["scope"])
+
+	// the requested scope cannot widen the original grant
+	resp, body = postForm(t, srv, "/token", refreshForm(body["refresh_token"].(string), "mjwt:read mjwt:admin"), "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "mjwt:read", body["scope"])
+	_, a, err := mjwt.ExtractClaims[auth.AccessTokenClaims](issuer.KeyStore(), body["access_token"].(string))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"mjwt:read"}, a.Claims.Perms.Dump())
+}
+
+func TestServer_RefreshConcurrent(t *testing.T) {
+	t.Parallel()
+	srv, _ := setupTestServer(t)
+	refreshToken := codeTokens(t, srv, "mjwt:read")["refresh_token"].(string)
+
+	var wg sync.WaitGroup
+	var success atomic.Int32
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, _ := postForm(t, srv, "/token", refreshForm(refreshToken, ""), "", "")
+			if resp.StatusCode == http.StatusOK {
+				success.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), success.Load())
+}
+
+func TestServer_AuthorizeErrors(t *testing.T) {
+	t.Parallel()
+	srv, _ := setupTestServer(t)
+
+	t.Run("public client without pkce", func(t *testing.T) {
+		loc := authorize(t, srv, url.Values{
+			"response_type": {"code"},
+			"client_id":     {"app"},
+			"redirect_uri":  {"https://app.example.com/callback"},
+			"state":         {"xyz"},
+		}, "user1")
+		assert.Equal(t, "invalid_request", loc.Query().Get("error"))
+		assert.Equal(t, "xyz", loc.Query().Get("state"))
+	})
+
+	t.Run("invalid redirect uri", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "/authorize?client_id=app&response_type=code&redirect_uri=https://evil.example.com")
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("login required", func(t *testing.T) {
+		resp, err := noRedirectClient().Get(srv.URL + "/authorize?" + url.Values{
+			"response_type":  {"code"},
+			"client_id":      {"app"},
+			"redirect_uri":   {"https://app.example.com/callback"},
+			"code_challenge": {PKCEChallenge(testVerifier)},
+		}.Encode())
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("wrong code verifier", func(t *testing.T) {
+		loc := authorize(t, srv, url.Values{
+			"response_type":         {"code"},
+			"client_id":             {"app"},
+			"redirect_uri":          {"https://app.example.com/callback"},
+			"code_challenge":        {PKCEChallenge(testVerifier)},
+			"code_challenge_method": {"S256"},
+		}, "user1")
+		resp, body := postForm(t, srv, "/token", url.Values{
+			"grant_type":    {"authorization_code"},
+			"client_id":     {"app"},
+			"code":          {loc.Query().Get("code")},
+			"redirect_uri":  {"https://app.example.com/callback"},
+			"code_verifier": {strings.Repeat("a", 43)},
+		}, "", "")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "invalid_grant", body["error"])
+	})
+}
+
+func TestServer_CodeBinding(t *testing.T) {
+	t.Parallel()
+	srv, _ := setupTestServer(t)
+
+	loc := authorize(t, srv, url.Values{
+		"response_type":         {"code"},
+		"client_id":             {"app"},
+		"redirect_uri":          {"https://app.example.com/callback"},
+		"code_challenge":        {PKCEChallenge(testVerifier)},
+		"code_challenge_method": {"S256"},
+	}, "user1")
+	tokenForm := url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {"app"},
+		"code":          {loc.Query().Get("code")},
+		"redirect_uri":  {"https://evil.example.com/callback"},
+		"code_verifier": {testVerifier},
+	}
+	resp, body := postForm(t, srv, "/token", tokenForm, "", "")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "invalid_grant", body["error"])
+
+	// the code is not consumed by a request with the wrong binding
+	tokenForm.Set("redirect_uri", "https://app.example.com/callback")
+	resp, _ = postForm(t, srv, "/token", tokenForm, "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestGrantPerms(t *testing.T) {
+	t.Parallel()
+	for _, i := range []struct {
+		user, client, scope string
+		grants, denies      []string
+	}{
+		// denies of the client are kept
+		{"files:read files:delete", "files:* !files:delete", "", []string{"files:read"}, []string{"files:delete"}},
+		// wildcard user grants are narrowed by the scope
+		{"files:*", "", "files:read", []string{"files:read"}, []string{"files:write"}},
+		{"files:*", "files:read files:write", "files:write openid", []string{"files:write"}, []string{"files:read", "files:delete"}},
+	} {
+		client := &Client{ID: "app"}
+		if i.client != "" {
+			client.Perms = auth.ParsePermStorage(i.client)
+		}
+		perms := grantPerms(&User{Perms: auth.ParsePermStorage(i.user)}, client, parseScope(i.scope))
+		for _, j := range i.grants {
+			assert.True(t, perms.Grants(j), "%s %s", i.user, j)
+		}
+		for _, j := range i.denies {
+			assert.False(t, perms.Grants(j), "%s %s", i.user, j)
+		}
+	}
+}
+
+func TestServer_EncodedClientSecret(t *testing.T) {
+	t.Parallel()
+	srv, issuer := setupTestServer(t)
+	token, err := auth.CreateAccessToken(issuer, "user1", "", nil, auth.ParsePermStorage("mjwt:read"))
+	assert.NoError(t, err)
+
+	// the introspection client form-urlencodes the credentials
+	resp, err := introspect.NewClient(srv.URL+"/introspect", "svc", testEncodedSecret).Introspect(context.Background(), token)
+	assert.NoError(t, err)
+	assert.True(t, resp.Active)
+
+	_, err = introspect.NewClient(srv.URL+"/introspect", "svc", "a/b+c=d").Introspect(context.Background(), token)
+	assert.Error(t, err)
+}
+
+func TestNewServer_MissingConfig(t *testing.T) {
+	t.Parallel()
+	_, err := NewServer(Config{})
+	assert.ErrorIs(t, err, ErrMissingConfig)
+	_, err = NewServer(Config{Issuer: &mjwt.Issuer{}, Clients: NewMemoryClientStore(), Users: NewMemoryUserStore()})
+	assert.ErrorIs(t, err, ErrMissingConfig)
+	assert.ErrorContains(t, err, "Authenticate")
+}
+
+func TestServer_ClientCredentials(t *testing.T) {
+	t.Parallel()
+	srv, issuer := setupTestServer(t)
+
+	resp, body := postForm(t, srv, "/token", url.Values{"grant_type": {"client_credentials"}, "scope": {"mjwt:bot"}}, "bot", "bot-secret")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Nil(t, body["refresh_token"])
+	_, a, err := mjwt.ExtractClaims[auth.AccessTokenClaims](issuer.KeyStore(), body["access_token"].(string))
+	assert.NoError(t, err)
+	assert.Equal(t, "bot", a.Subject)
+	assert.Equal(t, []string{"mjwt:bot"}, a.Claims.Perms.Dump())
+
+	resp, body = postForm(t, srv, "/token", url.Values{"grant_type": {"client_credentials"}, "client_id": {"app"}}, "", "")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "unauthorized_client", body["error"])
+
+	resp, body = postForm(t, srv, "/token", url.Values{"grant_type": {"password"}}, "bot", "bot-secret")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "unsupported_grant_type", body["error"])
+}
+
+func TestServer_Discovery(t *testing.T) {
+	t.Parallel()
+	srv, _ := setupTestServer(t)
+
+	resp, err := http.Get(srv.URL + "/.well-known/openid-configuration")
+	assert.NoError(t, err)
+	var doc discoveryDocument
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&doc))
+	resp.Body.Close()
+	assert.Equal(t, "https://auth.example.com", doc.Issuer)
+	assert.Equal(t, "https://auth.example.com/token", doc.TokenEndpoint)
+	assert.Equal(t, []string{"RS256"}, doc.IdTokenSigningAlgValuesSupported)
+
+	resp, err = http.Get(srv.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err)
+	var jwks struct {
+		Keys []map[string]interface{} `json:"keys"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&jwks))
+	resp.Body.Close()
+	assert.Len(t, jwks.Keys, 1)
+	assert.Equal(t, "key1", jwks.Keys[0]["kid"])
+}
@@ -0,0 +1,152 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"github.com/1f349/mjwt/auth"
+	"slices"
+	"sync"
+	"time"
+)
+
+var ErrClientNotFound = errors.New("client not found")
+var ErrUserNotFound = errors.New("user not found")
+
+// Client contains the registration of an OAuth client
+type Client struct {
+	ID           string
+	Secret       string
+	RedirectURIs []string
+
+	// Public clients have no secret and must use PKCE
+	Public bool
+
+	// Perms limits the permissions which can be granted to the client, a nil
+	// value allows all permissions of the user. For the client_credentials
+	// grant these are the permissions of the client itself.
+	Perms *auth.PermStorage
+}
+
+// HasRedirectURI outputs true if the redirect URI is registered for the client
+func (c *Client) HasRedirectURI(uri string) bool {
+	return slices.Contains(c.RedirectURIs, uri)
+}
+
+// User contains the subject, permissions and profile claims of a user
+type User struct {
+	Subject string
+	Perms   *auth.PermStorage
+	Profile auth.IDTokenClaims
+}
+
+// ClientStore provides lookup of registered clients
+type ClientStore interface {
+	GetClient(ctx context.Context, id string) (*Client, error)
+}
+
+// UserStore provides lookup of users by subject
+type UserStore interface {
+	GetUser(ctx context.Context, sub string) (*User, error)
+}
+
+// RevocationStore records revoked token IDs until the token expires
+type RevocationStore interface {
+	Revoke(ctx context.Context, jti string, exp time.Time) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// MemoryClientStore is an in-memory ClientStore
+type MemoryClientStore struct {
+	mu      sync.RWMutex
+	clients map[string]*Client
+}
+
+// NewMemoryClientStore creates a MemoryClientStore containing the clients
+func NewMemoryClientStore(clients ...*Client) *MemoryClientStore {
+	m := &MemoryClientStore{clients: make(map[string]*Client)}
+	for _, c := range clients {
+		m.Add(c)
+	}
+	return m
+}
+
+// Add registers or replaces a client
+func (m *MemoryClientStore) Add(c *Client) {
+	m.mu.Lock()
+	m.clients[c.ID] = c
+	m.mu.Unlock()
+}
+
+func (m *MemoryClientStore) GetClient(_ context.Context, id string) (*Client, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	c, ok := m.clients[id]
+	if !ok {
+		return nil, ErrClientNotFound
+	}
+	return c, nil
+}
+
+// MemoryUserStore is an in-memory UserStore
+type MemoryUserStore struct {
+	mu    sync.RWMutex
+	users map[string]*User
+}
+
+// NewMemoryUserStore creates a MemoryUserStore containing the users
+func NewMemoryUserStore(users ...*User) *MemoryUserStore {
+	m := &MemoryUserStore{users: make(map[string]*User)}
+	for _, u := range users {
+		m.Add(u)
+	}
+	return m
+}
+
+// Add registers or replaces a user
+func (m *MemoryUserStore) Add(u *User) {
+	m.mu.Lock()
+	m.users[u.Subject] = u
+	m.mu.Unlock()
+}
+
+func (m *MemoryUserStore) GetUser(_ context.Context, sub string) (*User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	u, ok := m.users[sub]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return u, nil
+}
+
+// MemoryRevocationStore is an in-memory RevocationStore, expired entries are
+// removed when new entries are added
+type MemoryRevocationStore struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time
+}
+
+// NewMemoryRevocationStore creates an empty MemoryRevocationStore
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{revoked: make(map[string]time.Time)}
+}
+
+func (m *MemoryRevocationStore) Revoke(_ context.Context, jti string, exp time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for k, v := range m.revoked {
+		if v.Before(now) {
+			delete(m.revoked, k)
+		}
+	}
+	m.revoked[jti] = exp
+	return nil
+}
+
+func (m *MemoryRevocationStore) IsRevoked(_ context.Context, jti string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.revoked[jti]
+	return ok, nil
+}
@@ -0,0 +1,185 @@
+package oauth
+
+import (
+	"errors"
+	"github.com/1f349/mjwt"
+	"github.com/1f349/mjwt/auth"
+	"github.com/golang-jwt/jwt/v4"
+	"net/http"
+	"slices"
+)
+
+// tokenResponse is the successful response from the token endpoint
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IdToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+func (s *Server) handleToken(rw http.ResponseWriter, req *http.Request) {
+	client, err := s.authenticateClient(req)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+
+	var resp *tokenResponse
+	switch req.PostFormValue("grant_type") {
+	case "authorization_code":
+		resp, err = s.authorizationCodeGrant(req, client)
+	case "refresh_token":
+		resp, err = s.refreshTokenGrant(req, client)
+	case "client_credentials":
+		resp, err = s.clientCredentialsGrant(req, client)
+	default:
+		err = errUnsupportedGrantType
+	}
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	writeJson(rw, http.StatusOK, resp)
+}
+
+func (s *Server) authorizationCodeGrant(req *http.Request, client *Client) (*tokenResponse, error) {
+	grant, ok := s.takeCode(req.PostFormValue("code"), client.ID, req.PostFormValue("redirect_uri"))
+	if !ok {
+		return nil, errInvalidGrant.with("invalid authorization code")
+	}
+	if grant.challenge != "" && !verifyPKCE(grant.challenge, grant.challengeType, req.PostFormValue("code_verifier")) {
+		return nil, errInvalidGrant.with("invalid code_verifier")
+	}
+
+	user, err := s.conf.Users.GetUser(req.Context(), grant.sub)
+	if err != nil {
+		return nil, lookupUserError(err)
+	}
+	resp, err := s.issueUserTokens(client, user, grant.scope)
+	if err != nil {
+		return nil, err
+	}
+
+	if hasScope(grant.scope, "openid") {
+		claims := user.Profile
+		claims.Nonce = grant.nonce
+		claims.AuthTime = jwt.NewNumericDate(grant.authTime)
+		claims.AuthorizedParty = client.ID
+		resp.IdToken, err = auth.CreateIDTokenWithDuration(s.conf.Issuer, s.conf.AccessTokenDuration, user.Subject, "", jwt.ClaimStrings{client.ID}, claims, resp.AccessToken)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+func (s *Server) refreshTokenGrant(req *http.Request, client *Client) (*tokenResponse, error) {
+	_, b, err := mjwt.ExtractClaims[auth.RefreshTokenClaims](s.conf.Issuer.KeyStore(), req.PostFormValue("refresh_token"))
+	if err != nil {
+		return nil, errInvalidGrant.with("invalid refresh token")
+	}
+	if !slices.Contains(b.Audience, client.ID) {
+		return nil, errInvalidGrant.with("refresh token was issued to another client")
+	}
+	revoked, err := s.conf.Revocations.IsRevoked(req.Context(), b.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, errInvalidGrant.with("refresh token has been revoked")
+	}
+
+	user, err := s.conf.Users.GetUser(req.Context(), b.Subject)
+	if err != nil {
+		return nil, lookupUserError(err)
+	}
+
+	// refresh tokens are single use, consuming the ID is atomic so concurrent
+	// redemptions of the same token cannot both succeed
+	if b.ID == "" || b.ExpiresAt == nil {
+		return nil, errInvalidGrant.with("invalid refresh token")
+	}
+	ok, err := s.conf.UsedRefreshTokens.Add(req.Context(), b.ID, b.ExpiresAt.Time)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errInvalidGrant.with("refresh token has already been used")
+	}
+	if err := s.conf.Revocations.Revoke(req.Context(), b.ID, b.ExpiresAt.Time); err != nil {
+		return nil, err
+	}
+
+	// the refreshed token is limited to the originally granted permissions and
+	// any requested scope can only narrow them further
+	granted := b.Claims.Perms
+	if granted == nil {
+		granted = auth.NewPermStorage()
+	}
+	perms := narrowScope(grantPerms(user, client, nil).Intersect(granted), parseScope(req.PostFormValue("scope")))
+	return s.issueTokens(client, user, perms)
+}
+
+func (s *Server) clientCredentialsGrant(req *http.Request, client *Client) (*tokenResponse, error) {
+	if client.Public {
+		return nil, errUnauthorizedClient.with("public clients cannot use client_credentials")
+	}
+	perms := client.Perms
+	if perms == nil {
+		perms = auth.NewPermStorage()
+	}
+	perms = narrowScope(perms, parseScope(req.PostFormValue("scope")))
+	accessToken, err := auth.CreateAccessTokenWithDuration(s.conf.Issuer, s.conf.AccessTokenDuration, client.ID, "", jwt.ClaimStrings{client.ID}, perms)
+	if err != nil {
+		return nil, err
+	}
+	return &tokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(s.conf.AccessTokenDuration.Seconds()),
+		Scope:       auth.FormatScope(perms),
+	}, nil
+}
+
+// issueUserTokens creates an access and refresh token pair for the user
+func (s *Server) issueUserTokens(client *Client, user *User, scope []string) (*tokenResponse, error) {
+	return s.issueTokens(client, user, grantPerms(user, client, scope))
+}
+
+// issueTokens creates an access and refresh token pair with the permissions,
+// the refresh token records the permissions to limit later refreshes
+func (s *Server) issueTokens(client *Client, user *User, perms *auth.PermStorage) (*tokenResponse, error) {
+	id, err := s.conf.Issuer.NewID()
+	if err != nil {
+		return nil, err
+	}
+	rId, err := s.conf.Issuer.NewID()
+	if err != nil {
+		return nil, err
+	}
+	aud := jwt.ClaimStrings{client.ID}
+	accessToken, err := auth.CreateAccessTokenWithDuration(s.conf.Issuer, s.conf.AccessTokenDuration, user.Subject, id, aud, perms)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := s.conf.Issuer.GenerateJwt(user.Subject, rId, aud, s.conf.RefreshTokenDuration, auth.RefreshTokenClaims{AccessTokenId: id, Perms: perms})
+	if err != nil {
+		return nil, err
+	}
+	return &tokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(s.conf.AccessTokenDuration.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        auth.FormatScope(perms),
+	}, nil
+}
+
+func lookupUserError(err error) error {
+	if errors.Is(err, ErrUserNotFound) {
+		return errInvalidGrant.with("unknown user")
+	}
+	return err
+}
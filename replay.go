@@ -0,0 +1,145 @@
+package mjwt
+
+import (
+	"context"
+	"errors"
+	"github.com/golang-jwt/jwt/v4"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+var ErrReplayed = errors.New("token has already been used")
+var ErrMissingReplayClaims = errors.New("missing jti or exp claim")
+
+// ReplayStore records seen token IDs until they expire
+type ReplayStore interface {
+	// Add records the jti until exp and outputs false if the jti has already
+	// been recorded and has not expired
+	Add(ctx context.Context, jti string, exp time.Time) (bool, error)
+}
+
+// DefaultReplayShards is the number of shards used by NewMemoryReplayStore when
+// a non-positive shard count is provided
+const DefaultReplayShards = 32
+
+// replaySweepInterval is the minimum time between removing expired entries
+// from a shard
+const replaySweepInterval = time.Minute
+
+// MemoryReplayStore is an in-memory ReplayStore. Entries are split between
+// shards to reduce lock contention.
+type MemoryReplayStore struct {
+	shards []*replayShard
+}
+
+type replayShard struct {
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	nextSweep time.Time
+}
+
+// NewMemoryReplayStore creates an empty MemoryReplayStore with the provided
+// number of shards
+func NewMemoryReplayStore(shards int) *MemoryReplayStore {
+	if shards <= 0 {
+		shards = DefaultReplayShards
+	}
+	m := &MemoryReplayStore{shards: make([]*replayShard, shards)}
+	for i := range m.shards {
+		m.shards[i] = &replayShard{seen: make(map[string]time.Time)}
+	}
+	return m
+}
+
+func (m *MemoryReplayStore) shard(jti string) *replayShard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(jti))
+	return m.shards[h.Sum32()%uint32(len(m.shards))]
+}
+
+func (m *MemoryReplayStore) Add(_ context.Context, jti string, exp time.Time) (bool, error) {
+	s := m.shard(jti)
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.After(s.nextSweep) {
+		s.sweep(now)
+		s.nextSweep = now.Add(replaySweepInterval)
+	}
+	if e, ok := s.seen[jti]; ok && e.After(now) {
+		return false, nil
+	}
+	s.seen[jti] = exp
+	return true, nil
+}
+
+// Len outputs the number of recorded entries including expired entries which
+// have not been removed yet
+func (m *MemoryReplayStore) Len() int {
+	n := 0
+	for _, s := range m.shards {
+		s.mu.Lock()
+		n += len(s.seen)
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// Sweep removes all expired entries
+func (m *MemoryReplayStore) Sweep() {
+	now := time.Now()
+	for _, s := range m.shards {
+		s.mu.Lock()
+		s.sweep(now)
+		s.mu.Unlock()
+	}
+}
+
+func (s *replayShard) sweep(now time.Time) {
+	for k, v := range s.seen {
+		if !v.After(now) {
+			delete(s.seen, k)
+		}
+	}
+}
+
+// ReplayGuard rejects tokens which have already been verified once
+type ReplayGuard struct {
+	store ReplayStore
+}
+
+// NewReplayGuard creates a ReplayGuard using the ReplayStore
+func NewReplayGuard(store ReplayStore) *ReplayGuard {
+	return &ReplayGuard{store: store}
+}
+
+// Check records the token ID until the token expires. A VerifyError with
+// ReasonReplayed is returned if the token ID has already been recorded.
+func (g *ReplayGuard) Check(ctx context.Context, claims jwt.RegisteredClaims) error {
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return NewVerifyError(ReasonInvalidClaims, ErrMissingReplayClaims)
+	}
+	fresh, err := g.store.Add(ctx, claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return NewVerifyError(ReasonReplayed, ErrReplayed)
+	}
+	return nil
+}
+
+// ExtractClaimsOnce uses ExtractClaims to validate the MJWT token and then
+// checks the token has not been used before with the ReplayGuard
+func ExtractClaimsOnce[T Claims](ctx context.Context, ks *KeyStore, guard *ReplayGuard, token string) (*jwt.Token, BaseTypeClaims[T], error) {
+	tok, b, err := ExtractClaims[T](ks, token)
+	if err != nil {
+		return nil, b, err
+	}
+	if err := guard.Check(ctx, b.RegisteredClaims); err != nil {
+		return nil, b, err
+	}
+	return tok, b, nil
+}
@@ -0,0 +1,107 @@
+package mjwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoryReplayStore(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	m := NewMemoryReplayStore(4)
+
+	fresh, err := m.Add(ctx, "a", time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, fresh)
+	fresh, err = m.Add(ctx, "a", time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, fresh)
+
+	// expired entries can be recorded again
+	fresh, err = m.Add(ctx, "b", time.Now().Add(-time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, fresh)
+	fresh, err = m.Add(ctx, "b", time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, fresh)
+
+	_, err = m.Add(ctx, "c", time.Now().Add(-time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, m.Len())
+	m.Sweep()
+	assert.Equal(t, 2, m.Len())
+}
+
+func TestMemoryReplayStore_Concurrent(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	m := NewMemoryReplayStore(0)
+	exp := time.Now().Add(time.Minute)
+
+	var fresh atomic.Int32
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 100 {
+				ok, err := m.Add(ctx, fmt.Sprint(i), exp)
+				assert.NoError(t, err)
+				if ok {
+					fresh.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(100), fresh.Load())
+}
+
+func TestExtractClaimsOnce(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	kStore := NewKeyStore()
+	s := newTestIssuer(t, "key1", kStore)
+	guard := NewReplayGuard(NewMemoryReplayStore(0))
+
+	token, err := s.GenerateJwt("1", "", nil, time.Minute, testClaims{TestValue: "hello"})
+	assert.NoError(t, err)
+
+	_, b, err := ExtractClaimsOnce[testClaims](ctx, kStore, guard, token)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", b.Subject)
+
+	_, _, err = ExtractClaimsOnce[testClaims](ctx, kStore, guard, token)
+	assert.ErrorIs(t, err, ErrReplayed)
+	assert.ErrorIs(t, err, ErrVerifyReplayed)
+	assert.Equal(t, "replayed", VerifyReasonOf(err).String())
+
+	// invalid tokens are not recorded
+	_, _, err = ExtractClaimsOnce[testClaims](ctx, kStore, guard, "invalid")
+	assert.ErrorIs(t, err, ErrVerifyMalformed)
+}
+
+type errReplayStore struct{}
+
+func (errReplayStore) Add(context.Context, string, time.Time) (bool, error) {
+	return false, errors.New("store unavailable")
+}
+
+func TestReplayGuard_Check(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	guard := NewReplayGuard(NewMemoryReplayStore(1))
+	err := guard.Check(ctx, jwt.RegisteredClaims{ID: "a"})
+	assert.ErrorIs(t, err, ErrMissingReplayClaims)
+	assert.ErrorIs(t, err, ErrVerifyInvalidClaims)
+
+	err = NewReplayGuard(errReplayStore{}).Check(ctx, jwt.RegisteredClaims{ID: "a", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))})
+	assert.EqualError(t, err, "store unavailable")
+}
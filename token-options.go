@@ -0,0 +1,89 @@
+package mjwt
+
+import (
+	"github.com/golang-jwt/jwt/v4"
+	"time"
+)
+
+// DefaultDuration is the duration used by Issuer.Issue when no duration option
+// is provided
+const DefaultDuration = 15 * time.Minute
+
+// tokenOptions contains the settings for generating a single token
+type tokenOptions struct {
+	sub      string
+	id       string
+	aud      jwt.ClaimStrings
+	dur      time.Duration
+	nbf      time.Duration
+	noIat    bool
+	noExp    bool
+	randomID bool
+	header   map[string]interface{}
+}
+
+func newTokenOptions(opts []TokenOption) *tokenOptions {
+	o := &tokenOptions{dur: DefaultDuration}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// TokenOption configures a single token generated by Issuer.Issue
+type TokenOption func(o *tokenOptions)
+
+// WithSubject sets the subject (sub) claim
+func WithSubject(sub string) TokenOption {
+	return func(o *tokenOptions) { o.sub = sub }
+}
+
+// WithID sets the JWT ID (jti) claim
+func WithID(id string) TokenOption {
+	return func(o *tokenOptions) { o.id = id }
+}
+
+// WithRandomID generates a JWT ID (jti) claim if no ID is provided, even when
+// the Issuer has ID generation disabled
+func WithRandomID() TokenOption {
+	return func(o *tokenOptions) { o.randomID = true }
+}
+
+// WithAudience sets the audience (aud) claim, this replaces the default
+// audience of the Issuer
+func WithAudience(aud ...string) TokenOption {
+	return func(o *tokenOptions) { o.aud = aud }
+}
+
+// WithDuration sets the time from issuing until the token expires
+func WithDuration(dur time.Duration) TokenOption {
+	return func(o *tokenOptions) { o.dur = dur }
+}
+
+// WithNotBeforeOffset sets the not before (nbf) claim to an offset from the
+// time of issuing. The expiry is still calculated from the time of issuing.
+func WithNotBeforeOffset(offset time.Duration) TokenOption {
+	return func(o *tokenOptions) { o.nbf = offset }
+}
+
+// WithoutIssuedAt omits the issued at (iat) claim
+func WithoutIssuedAt() TokenOption {
+	return func(o *tokenOptions) { o.noIat = true }
+}
+
+// WithoutExpiry omits the expiry (exp) claim, tokens without an expiry should
+// be checked against a revocation list or registry when verified
+func WithoutExpiry() TokenOption {
+	return func(o *tokenOptions) { o.noExp = true }
+}
+
+// WithHeader adds an extra header field to the token. The "alg" and "kid"
+// fields are controlled by the Issuer and cannot be changed.
+func WithHeader(key string, value interface{}) TokenOption {
+	return func(o *tokenOptions) {
+		if o.header == nil {
+			o.header = make(map[string]interface{})
+		}
+		o.header[key] = value
+	}
+}
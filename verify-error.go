@@ -0,0 +1,146 @@
+package mjwt
+
+import (
+	"errors"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// VerifyReason is a stable identifier for the reason a token failed verification
+type VerifyReason int
+
+const (
+	ReasonUnknown VerifyReason = iota
+	ReasonExpired
+	ReasonNotYetValid
+	ReasonBadSignature
+	ReasonUnknownKid
+	ReasonWrongType
+	ReasonWrongAudience
+	ReasonRevoked
+	ReasonMalformed
+	ReasonInvalidClaims
+	ReasonReplayed
+)
+
+var verifyReasonNames = map[VerifyReason]string{
+	ReasonUnknown:       "unknown",
+	ReasonExpired:       "expired",
+	ReasonNotYetValid:   "not-yet-valid",
+	ReasonBadSignature:  "bad-signature",
+	ReasonUnknownKid:    "unknown-kid",
+	ReasonWrongType:     "wrong-type",
+	ReasonWrongAudience: "wrong-audience",
+	ReasonRevoked:       "revoked",
+	ReasonMalformed:     "malformed",
+	ReasonInvalidClaims: "invalid-claims",
+	ReasonReplayed:      "replayed",
+}
+
+// String outputs the stable name of the VerifyReason, this is suitable for use
+// as a metric label
+func (r VerifyReason) String() string {
+	if s, ok := verifyReasonNames[r]; ok {
+		return s
+	}
+	return verifyReasonNames[ReasonUnknown]
+}
+
+// Sentinel errors for each VerifyReason, these can be used with errors.Is to
+// match any VerifyError with the same reason
+var (
+	ErrVerifyExpired       = &VerifyError{Reason: ReasonExpired}
+	ErrVerifyNotYetValid   = &VerifyError{Reason: ReasonNotYetValid}
+	ErrVerifyBadSignature  = &VerifyError{Reason: ReasonBadSignature}
+	ErrVerifyUnknownKid    = &VerifyError{Reason: ReasonUnknownKid}
+	ErrVerifyWrongType     = &VerifyError{Reason: ReasonWrongType}
+	ErrVerifyWrongAudience = &VerifyError{Reason: ReasonWrongAudience}
+	ErrVerifyRevoked       = &VerifyError{Reason: ReasonRevoked}
+	ErrVerifyMalformed     = &VerifyError{Reason: ReasonMalformed}
+	ErrVerifyInvalidClaims = &VerifyError{Reason: ReasonInvalidClaims}
+	ErrVerifyReplayed      = &VerifyError{Reason: ReasonReplayed}
+)
+
+// VerifyError is returned when a token fails verification. The Reason field
+// contains a stable VerifyReason and Err contains the underlying error.
+type VerifyError struct {
+	Reason VerifyReason
+	Err    error
+}
+
+// NewVerifyError creates a VerifyError wrapping err with the provided reason
+func NewVerifyError(reason VerifyReason, err error) *VerifyError {
+	return &VerifyError{Reason: reason, Err: err}
+}
+
+func (v *VerifyError) Error() string {
+	if v.Err == nil {
+		return "mjwt: verify: " + v.Reason.String()
+	}
+	return "mjwt: verify: " + v.Reason.String() + ": " + v.Err.Error()
+}
+
+// Unwrap provides access to the underlying error for errors.Is and errors.As
+func (v *VerifyError) Unwrap() error { return v.Err }
+
+// Is matches any VerifyError target with the same Reason and no underlying
+// error, this allows the ErrVerify sentinel errors to match
+func (v *VerifyError) Is(target error) bool {
+	t, ok := target.(*VerifyError)
+	if !ok {
+		return false
+	}
+	return t.Err == nil && t.Reason == v.Reason
+}
+
+// VerifyReasonOf outputs the VerifyReason of the first VerifyError found in the
+// error chain or ReasonUnknown if there is none
+func VerifyReasonOf(err error) VerifyReason {
+	var v *VerifyError
+	if errors.As(err, &v) {
+		return v.Reason
+	}
+	return ReasonUnknown
+}
+
+// wrapVerifyError converts errors from parsing and validating a token into a
+// VerifyError with the matching reason
+func wrapVerifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	// already converted
+	var v *VerifyError
+	if errors.As(err, &v) {
+		return err
+	}
+
+	return NewVerifyError(classifyVerifyError(err), err)
+}
+
+func classifyVerifyError(err error) VerifyReason {
+	switch {
+	case errors.Is(err, ErrMissingPublicKey):
+		return ReasonUnknownKid
+	case errors.Is(err, ErrClaimTypeMismatch):
+		return ReasonWrongType
+	}
+
+	var vErr *jwt.ValidationError
+	if !errors.As(err, &vErr) {
+		return ReasonInvalidClaims
+	}
+	switch {
+	case vErr.Errors&jwt.ValidationErrorMalformed != 0:
+		return ReasonMalformed
+	case vErr.Errors&(jwt.ValidationErrorUnverifiable|jwt.ValidationErrorSignatureInvalid) != 0:
+		return ReasonBadSignature
+	case vErr.Errors&jwt.ValidationErrorExpired != 0:
+		return ReasonExpired
+	case vErr.Errors&(jwt.ValidationErrorNotValidYet|jwt.ValidationErrorIssuedAt) != 0:
+		return ReasonNotYetValid
+	case vErr.Errors&jwt.ValidationErrorAudience != 0:
+		return ReasonWrongAudience
+	}
+	return ReasonInvalidClaims
+}
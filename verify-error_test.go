@@ -0,0 +1,92 @@
+package mjwt
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestVerifyError(t *testing.T) {
+	t.Parallel()
+	kStore := NewKeyStore()
+	s := newTestIssuer(t, "key1", kStore)
+
+	t.Run("expired", func(t *testing.T) {
+		t.Parallel()
+		token, err := s.GenerateJwt("1", "test", nil, -time.Minute, testClaims{TestValue: "hello"})
+		assert.NoError(t, err)
+		_, _, err = ExtractClaims[testClaims](kStore, token)
+		assert.ErrorIs(t, err, ErrVerifyExpired)
+		assert.ErrorIs(t, err, jwt.ErrTokenExpired)
+		assert.Equal(t, ReasonExpired, VerifyReasonOf(err))
+	})
+
+	t.Run("not yet valid", func(t *testing.T) {
+		t.Parallel()
+		b := wrapClaims[testClaims]("1", "test", "mjwt.test", nil, time.Hour, testClaims{TestValue: "hello"})
+		b.NotBefore = jwt.NewNumericDate(time.Now().Add(time.Minute))
+		token, err := s.SignJwt(b)
+		assert.NoError(t, err)
+		_, _, err = ExtractClaims[testClaims](kStore, token)
+		assert.ErrorIs(t, err, ErrVerifyNotYetValid)
+	})
+
+	t.Run("bad signature", func(t *testing.T) {
+		t.Parallel()
+		token, err := s.GenerateJwt("1", "test", nil, time.Minute, testClaims{TestValue: "hello"})
+		assert.NoError(t, err)
+		_, _, err = ExtractClaims[testClaims](kStore, token[:len(token)-4]+"AAAA")
+		assert.ErrorIs(t, err, ErrVerifyBadSignature)
+	})
+
+	t.Run("unknown kid", func(t *testing.T) {
+		t.Parallel()
+		kStore2 := NewKeyStore()
+		s2 := newTestIssuer(t, "key2", kStore2)
+		token, err := s2.GenerateJwt("1", "test", nil, time.Minute, testClaims{TestValue: "hello"})
+		assert.NoError(t, err)
+		_, _, err = ExtractClaims[testClaims](kStore, token)
+		assert.ErrorIs(t, err, ErrVerifyUnknownKid)
+		assert.ErrorIs(t, err, ErrMissingPublicKey)
+	})
+
+	t.Run("wrong type", func(t *testing.T) {
+		t.Parallel()
+		token, err := s.GenerateJwt("1", "test", nil, time.Minute, testClaims{TestValue: "world"})
+		assert.NoError(t, err)
+		_, _, err = ExtractClaims[testClaims2](kStore, token)
+		assert.ErrorIs(t, err, ErrVerifyWrongType)
+		assert.ErrorIs(t, err, ErrClaimTypeMismatch)
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		t.Parallel()
+		_, _, err := ExtractClaims[testClaims](kStore, "not-a-token")
+		assert.ErrorIs(t, err, ErrVerifyMalformed)
+		var vErr *VerifyError
+		assert.True(t, errors.As(err, &vErr))
+		assert.Equal(t, "malformed", vErr.Reason.String())
+	})
+
+	t.Run("invalid claims", func(t *testing.T) {
+		t.Parallel()
+		token, err := s.GenerateJwt("1", "test", nil, time.Minute, testClaims{TestValue: "test"})
+		assert.NoError(t, err)
+		_, _, err = ExtractClaims[testClaims](kStore, token)
+		assert.ErrorIs(t, err, ErrVerifyInvalidClaims)
+		assert.False(t, errors.Is(err, ErrVerifyExpired))
+	})
+}
+
+func TestVerifyError_Error(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "mjwt: verify: revoked", ErrVerifyRevoked.Error())
+	err := fmt.Errorf("wrapped: %w", NewVerifyError(ReasonRevoked, errors.New("token revoked")))
+	assert.Equal(t, "wrapped: mjwt: verify: revoked: token revoked", err.Error())
+	assert.ErrorIs(t, err, ErrVerifyRevoked)
+	assert.Equal(t, ReasonRevoked, VerifyReasonOf(err))
+	assert.Equal(t, ReasonUnknown, VerifyReasonOf(errors.New("other")))
+}